@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/casbin/casbin/v2/model"
 	"github.com/casbin/casbin/v2/persist"
@@ -24,13 +28,88 @@ var (
 	_ persist.BatchAdapter = (*bunAdapter)(nil)
 	// check if the bunAdapter implements the UpdatableAdapter interface
 	_ persist.UpdatableAdapter = (*bunAdapter)(nil)
+	// check if the bunAdapter implements the FilteredAdapter interface
+	_ persist.FilteredAdapter = (*bunAdapter)(nil)
+	// check if the bunAdapter implements the ContextAdapter interface
+	_ persist.ContextAdapter = (*bunAdapter)(nil)
+	// check if the bunAdapter implements the ContextBatchAdapter interface
+	_ persist.ContextBatchAdapter = (*bunAdapter)(nil)
+	// check if the bunAdapter implements the ContextUpdatableAdapter interface
+	_ persist.ContextUpdatableAdapter = (*bunAdapter)(nil)
+	// check if the bunAdapter implements the ContextFilteredAdapter interface
+	_ persist.ContextFilteredAdapter = (*bunAdapter)(nil)
+	// check if the bunAdapter implements the Adapter interface of this package
+	_ Adapter = (*bunAdapter)(nil)
 )
 
+// Adapter is the Casbin adapter backed by Bun.
+// Besides the interfaces of Casbin, it provides the operations specific to this package.
+type Adapter interface {
+	persist.Adapter
+	persist.BatchAdapter
+	persist.UpdatableAdapter
+	persist.FilteredAdapter
+
+	// Export writes all policy rules in the storage to w in the given format.
+	Export(ctx context.Context, w io.Writer, format Format) error
+	// Import reads policy rules in the given format from r and stores them in one transaction.
+	Import(ctx context.Context, r io.Reader, format Format, mode ImportMode) error
+
+	// InspectSchema compares the live policy table with CasbinPolicy.
+	InspectSchema(ctx context.Context) ([]ColumnDiff, error)
+	// UpgradeSchema applies the safe changes that InspectSchema reports and returns the others.
+	UpgradeSchema(ctx context.Context) ([]ColumnDiff, error)
+	// MigrateFrom copies the rules of a table of another layout into the policy table.
+	MigrateFrom(ctx context.Context, layout TableLayout, opts MigrateOptions) (MigrateReport, error)
+
+	// AddPolicyWithExpiry adds a policy rule that is only in effect from validFrom until expiresAt.
+	AddPolicyWithExpiry(ctx context.Context, sec string, ptype string, rule []string, validFrom, expiresAt time.Time) error
+	// Purge deletes the expired policy rules and returns them.
+	Purge(ctx context.Context) ([]ExpiredPolicy, error)
+
+	// Ping checks that the database is reachable and that the policy table exists.
+	Ping(ctx context.Context) error
+	// Verify compares the stored policy rules with the rules of the model.
+	Verify(ctx context.Context, m model.Model) (VerifyReport, error)
+	// Deduplicate deletes the rows that repeat the rule of another row and returns their number.
+	Deduplicate(ctx context.Context) (int64, error)
+	// AddPoliciesIgnoringDuplicates adds the policy rules that are not stored yet and returns them.
+	AddPoliciesIgnoringDuplicates(ctx context.Context, sec string, ptype string, rules [][]string) ([][]string, error)
+
+	// FindPolicies returns a page of the stored policy rules that match the filter and the cursor of the next page.
+	FindPolicies(ctx context.Context, ptype string, filter PolicyFilter) ([][]string, string, error)
+	// CountPolicies returns the number of stored policy rules that match the filter.
+	CountPolicies(ctx context.Context, ptype string, filter PolicyFilter) (int, error)
+}
+
 type bunAdapter struct {
-	db *bun.DB
+	db               *bun.DB
+	replicas         *replicaSet
+	readYourWrites   time.Duration
+	tenantID         string
+	namespace        string
+	loadPageSize     int
+	fastLoad         FastLoad
+	isFiltered       bool
+	retryPolicy      RetryPolicy
+	columnWidth      int
+	caseSensitive    bool
+	modelType        reflect.Type
+	timeBound        bool
+	liveScopeColumns map[string]bool
+	telemetry        *telemetry
+	logger           *slog.Logger
+	logRuleValues    bool
+	ignoreDuplicates bool
+	revisions        *revisionSet
+	lockTimeout      time.Duration
+	policyNotFound   bool
 }
 
-func NewAdapter(driverName, dataSourceName string) (persist.Adapter, error) {
+// Option configures the adapter created by the constructors.
+type Option func(*bunAdapter)
+
+func NewAdapter(driverName, dataSourceName string, opts ...Option) (Adapter, error) {
 	sqlDB, err := openSqlDB(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
@@ -41,21 +120,29 @@ func NewAdapter(driverName, dataSourceName string) (persist.Adapter, error) {
 		return nil, err
 	}
 
-	b, err := newAdapter(db)
+	b, err := newAdapter(db, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	// the database was opened by the adapter, so it is closed with it,
+	// unlike the databases that the caller passes to the other constructors and may share
+	runtime.SetFinalizer(b, func(a *bunAdapter) {
+		if err := a.db.Close(); err != nil {
+			panic(err)
+		}
+	})
+
 	return b, nil
 }
 
-func NewAdapterWithSqlDB(sqlDB *sql.DB, driverName string) (persist.Adapter, error) {
+func NewAdapterWithSqlDB(sqlDB *sql.DB, driverName string, opts ...Option) (Adapter, error) {
 	db, err := openBunDB(sqlDB, driverName)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := newAdapter(db)
+	b, err := newAdapter(db, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,8 +150,8 @@ func NewAdapterWithSqlDB(sqlDB *sql.DB, driverName string) (persist.Adapter, err
 	return b, nil
 }
 
-func NewAdapterWithBunDB(db *bun.DB) (persist.Adapter, error) {
-	b, err := newAdapter(db)
+func NewAdapterWithBunDB(db *bun.DB, opts ...Option) (Adapter, error) {
+	b, err := newAdapter(db, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -72,20 +159,37 @@ func NewAdapterWithBunDB(db *bun.DB) (persist.Adapter, error) {
 	return b, nil
 }
 
-func newAdapter(db *bun.DB) (persist.Adapter, error) {
+func newAdapter(db *bun.DB, opts ...Option) (*bunAdapter, error) {
 	b := &bunAdapter{
-		db: db,
+		db:          db,
+		modelType:   casbinPolicyType,
+		columnWidth: DefaultColumnWidth,
+		revisions:   newRevisionSet(),
+		lockTimeout: DefaultLockTimeout,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if err := b.checkScopeWidth(); err != nil {
+		return nil, err
+	}
+	if b.telemetry != nil || b.logger != nil {
+		addRowsHook(b.db)
 	}
 
 	if err := b.createTable(); err != nil {
 		return nil, err
 	}
-
-	runtime.SetFinalizer(b, func(a *bunAdapter) {
-		if err := a.db.Close(); err != nil {
-			panic(err)
-		}
-	})
+	timeBound, err := b.detectTimeBound(context.Background(), b.db)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	b.timeBound = timeBound
+	liveScopeColumns, err := b.detectScopeColumns(context.Background(), b.db)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	b.liveScopeColumns = liveScopeColumns
 
 	return b, nil
 }
@@ -101,7 +205,7 @@ func openSqlDB(driverName, dataSourceName string) (*sql.DB, error) {
 	// case "sqlite3":
 	// 	return sql.Open(sqliteshim.ShimName, dataSourceName)
 	default:
-		return nil, fmt.Errorf("unsupported driver: %s", driverName)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, driverName)
 	}
 }
 
@@ -116,37 +220,147 @@ func openBunDB(sqlDB *sql.DB, driverName string) (*bun.DB, error) {
 	case "sqlite3":
 		return bun.NewDB(sqlDB, sqlitedialect.New()), nil
 	default:
-		return nil, fmt.Errorf("unsupported driver: %s", driverName)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, driverName)
 	}
 }
 
 func (a *bunAdapter) createTable() error {
-	if _, err := a.db.NewCreateTable().
-		Model((*CasbinPolicy)(nil)).
-		IfNotExists().
-		Exec(context.Background()); err != nil {
-		return err
+	ctx := context.Background()
+	return classifyError(a.withLock(ctx, func(db bun.IDB) error {
+		columnTypeMu.Lock()
+		defer columnTypeMu.Unlock()
+
+		a.setColumnTypes()
+		if _, err := db.NewCreateTable().
+			Model(a.tableModel()).
+			IfNotExists().
+			Exec(ctx); err != nil {
+			return err
+		}
+		return a.createMetaTable(ctx, db)
+	}))
+}
+
+// WithLoadPageSize makes LoadPolicy read the table in batches of size rows,
+// paginating by id instead of keeping a single cursor open over the whole table.
+// The page size is ignored by FastLoadDistinct, whose rows have no id.
+func WithLoadPageSize(size int) Option {
+	return func(a *bunAdapter) {
+		a.loadPageSize = size
 	}
-	return nil
 }
 
 // LoadPolicy loads all policy rules from the storage.
+// Rows are added to the model as they are read, so the rows are never held in memory at once.
 func (a *bunAdapter) LoadPolicy(model model.Model) error {
-	var policies []CasbinPolicy
-	err := a.db.NewSelect().
-		Model(&policies).
-		Scan(context.Background())
+	return a.LoadPolicyCtx(context.Background(), model)
+}
+
+// LoadPolicyCtx loads all policy rules from the storage with context.
+func (a *bunAdapter) LoadPolicyCtx(ctx context.Context, model model.Model) (err error) {
+	ctx, op := a.startOperation(ctx, "LoadPolicy")
+	defer func() { op.end(err) }()
+
+	a.isFiltered = false
+	count := 0
+	defer func() { op.setRuleCount(count) }()
+	// the revision and the rules are read from the same database, and the revision first,
+	// so that a change made during the load makes SavePolicy fail
+	db := a.readDB()
+	revision, err := a.readRevision(ctx, db)
 	if err != nil {
+		return classifyError(err)
+	}
+	if a.fastLoad == FastLoadOff {
+		if err := a.scanPolicies(ctx, db, func(policy CasbinPolicy) error {
+			count++
+			return loadPolicyRecord(policy, model)
+		}); err != nil {
+			return classifyError(err)
+		}
+		a.revisions.set(a.tenantID, revision)
+		return nil
+	}
+
+	batch := &policyBatch{model: model}
+	if err := a.scanPolicies(ctx, db, func(policy CasbinPolicy) error {
+		count++
+		return batch.add(policy)
+	}); err != nil {
+		return classifyError(err)
+	}
+	if err := batch.flush(); err != nil {
 		return err
 	}
+	a.revisions.set(a.tenantID, revision)
+	return nil
+}
 
-	for _, policy := range policies {
-		if err := loadPolicyRecord(policy, model); err != nil {
+// scanPolicies calls fn for each policy visible to the adapter in db,
+// following the page size and fast load settings.
+func (a *bunAdapter) scanPolicies(ctx context.Context, db bun.IDB, fn func(policy CasbinPolicy) error) error {
+	now := time.Now()
+	if a.fastLoad == FastLoadDistinct {
+		return a.forEachPolicy(ctx, activeQuery(a, a.selectDistinctPolicies(db), now), fn)
+	}
+	if a.loadPageSize > 0 {
+		return a.forEachPolicyPage(ctx, db, now, fn)
+	}
+	return a.forEachPolicy(ctx, activeQuery(a, a.selectPolicies(db), now), fn)
+}
+
+// forEachPolicyPage reads the policies of db in keyset-paginated batches ordered by id.
+func (a *bunAdapter) forEachPolicyPage(ctx context.Context, db bun.IDB, now time.Time, fn func(policy CasbinPolicy) error) error {
+	var lastID int64
+	for {
+		count := 0
+		query := activeQuery(a, a.selectPolicies(db), now).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(a.loadPageSize)
+		if err := a.forEachPolicy(ctx, query, func(policy CasbinPolicy) error {
+			count++
+			lastID = policy.ID
+			return fn(policy)
+		}); err != nil {
 			return err
 		}
+		if count < a.loadPageSize {
+			return nil
+		}
 	}
+}
 
-	return nil
+// selectPolicies returns a query that selects the policies of db visible to the adapter.
+// The reads of one operation pass the same db, so that they see the same replica.
+func (a *bunAdapter) selectPolicies(db bun.IDB) *bun.SelectQuery {
+	query := db.NewSelect().
+		Model(a.tableModel())
+	query = excludeColumns(a, query)
+	return scopeQuery(a, query)
+}
+
+// forEachPolicy runs the query and calls fn for each row as it arrives.
+func (a *bunAdapter) forEachPolicy(ctx context.Context, query *bun.SelectQuery, fn func(policy CasbinPolicy) error) error {
+	rows, err := query.Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		policy, err := a.scanPolicy(func(dest interface{}) error {
+			return a.db.ScanRow(ctx, rows, dest)
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(policy); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
 }
 
 func loadPolicyRecord(policy CasbinPolicy, model model.Model) error {
@@ -165,95 +379,189 @@ func loadPolicyRecord(policy CasbinPolicy, model model.Model) error {
 
 // SavePolicy saves all policy rules to the storage.
 func (a *bunAdapter) SavePolicy(model model.Model) error {
+	return a.SavePolicyCtx(context.Background(), model)
+}
+
+// SavePolicyCtx saves all policy rules to the storage with context.
+func (a *bunAdapter) SavePolicyCtx(ctx context.Context, model model.Model) (err error) {
+	ctx, op := a.startOperation(ctx, "SavePolicy")
+	defer func() { op.end(err) }()
+
+	if a.isFiltered {
+		return ErrFilteredSaveForbidden
+	}
+
 	policies := make([]CasbinPolicy, 0)
 
 	// go through policy definitions
 	for ptype, ast := range model["p"] {
 		for _, rule := range ast.Policy {
-			policies = append(policies, newCasbinPolicy(ptype, rule))
+			policy, err := a.newPolicy(ptype, rule)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, policy)
 		}
 	}
 
 	// go through role definitions
 	for ptype, ast := range model["g"] {
 		for _, rule := range ast.Policy {
-			policies = append(policies, newCasbinPolicy(ptype, rule))
+			policy, err := a.newPolicy(ptype, rule)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, policy)
 		}
 	}
 
-	return a.savePolicyRecords(policies)
-}
-
-func (a *bunAdapter) savePolicyRecords(policies []CasbinPolicy) error {
-	// delete existing policies
-	if err := a.refreshTable(); err != nil {
+	if err := a.checkWidth(policies...); err != nil {
 		return err
 	}
+	op.setRuleCount(len(policies))
+	return classifyError(a.withLock(ctx, func(db bun.IDB) error {
+		return a.savePolicyRecords(ctx, db, policies)
+	}))
+}
 
-	// bulk insert new policies
-	if _, err := a.db.NewInsert().
-		Model(&policies).
-		Exec(context.Background()); err != nil {
-		return err
+// savePolicyRecords replaces the policies of the adapter's tenant and namespace on db,
+// in one transaction that increments the revision from the one that LoadPolicy read,
+// or from any revision if the adapter has not loaded the policies.
+// The rows are deleted rather than truncated, since a truncate commits on MySQL.
+func (a *bunAdapter) savePolicyRecords(ctx context.Context, db bun.IDB, policies []CasbinPolicy) error {
+	expected, ok := a.revisions.get(a.tenantID)
+	if !ok {
+		expected = anyRevision
 	}
 
-	return nil
-}
+	var revision int64
+	if err := a.runInTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		if revision, err = a.bumpRevision(ctx, tx, expected); err != nil {
+			return err
+		}
+		records, err := a.keepTimeBounds(ctx, tx, policies)
+		if err != nil {
+			return err
+		}
 
-// truncate tables
-func (a *bunAdapter) refreshTable() error {
-	if _, err := a.db.NewTruncateTable().
-		Model((*CasbinPolicy)(nil)).
-		Exec(context.Background()); err != nil {
+		query := tx.NewDelete().
+			Model(a.tableModel())
+		if !a.scoped() {
+			query = query.Where("1 = 1")
+		}
+		if _, err := scopeQuery(a, query).Exec(ctx); err != nil {
+			return err
+		}
+
+		return a.insertPolicies(ctx, tx, records)
+	}); err != nil {
 		return err
 	}
+	a.revisions.set(a.tenantID, revision)
 	return nil
 }
 
 // AddPolicy adds a policy rule to the storage.
 // This is part of the Auto-Save feature.
 func (a *bunAdapter) AddPolicy(sec string, ptype string, rule []string) error {
-	newPolicy := newCasbinPolicy(ptype, rule)
-	if _, err := a.db.NewInsert().
-		Model(&newPolicy).
-		Exec(context.Background()); err != nil {
+	return a.AddPolicyCtx(context.Background(), sec, ptype, rule)
+}
+
+// AddPolicyCtx adds a policy rule to the storage with context.
+// This is part of the Auto-Save feature.
+func (a *bunAdapter) AddPolicyCtx(ctx context.Context, sec string, ptype string, rule []string) (err error) {
+	ctx, op := a.startOperation(ctx, "AddPolicy", ptypeKey.String(ptype), ruleCountKey.Int(1))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("rule", rule))
+
+	newPolicy, err := a.newPolicy(ptype, rule)
+	if err != nil {
 		return err
 	}
-	return nil
+	if err := a.checkWidth(newPolicy); err != nil {
+		return err
+	}
+	if a.ignoreDuplicates {
+		_, err := a.insertAbsentPolicies(ctx, []CasbinPolicy{newPolicy})
+		return err
+	}
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		return a.insertPolicies(ctx, tx, []CasbinPolicy{newPolicy})
+	})
 }
 
 // AddPolicies adds policy rules to the storage.
 // This is part of the Auto-Save feature.
 func (a *bunAdapter) AddPolicies(sec string, ptype string, rules [][]string) error {
-	policies := make([]CasbinPolicy, 0)
-	for _, rule := range rules {
-		policies = append(policies, newCasbinPolicy(ptype, rule))
+	return a.AddPoliciesCtx(context.Background(), sec, ptype, rules)
+}
+
+// AddPoliciesCtx adds policy rules to the storage with context.
+// This is part of the Auto-Save feature.
+func (a *bunAdapter) AddPoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) (err error) {
+	ctx, op := a.startOperation(ctx, "AddPolicies", ptypeKey.String(ptype), ruleCountKey.Int(len(rules)))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("rules", rules))
+
+	policies, err := a.newPolicies(ptype, rules)
+	if err != nil {
+		return err
 	}
-	if _, err := a.db.NewInsert().
-		Model(&policies).
-		Exec(context.Background()); err != nil {
+	if err := a.checkWidth(policies...); err != nil {
 		return err
 	}
-	return nil
+	if a.ignoreDuplicates {
+		_, err := a.insertAbsentPolicies(ctx, policies)
+		return err
+	}
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		return a.insertPolicies(ctx, tx, policies)
+	})
 }
 
 // RemovePolicy removes a policy rule from the storage.
 // This is part of the Auto-Save feature.
 func (a *bunAdapter) RemovePolicy(sec string, ptype string, rule []string) error {
-	exisingPolicy := newCasbinPolicy(ptype, rule)
-	if err := a.deleteRecord(exisingPolicy); err != nil {
+	return a.RemovePolicyCtx(context.Background(), sec, ptype, rule)
+}
+
+// RemovePolicyCtx removes a policy rule from the storage with context.
+// This is part of the Auto-Save feature.
+func (a *bunAdapter) RemovePolicyCtx(ctx context.Context, sec string, ptype string, rule []string) (err error) {
+	ctx, op := a.startOperation(ctx, "RemovePolicy", ptypeKey.String(ptype), ruleCountKey.Int(1))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("rule", rule))
+
+	exisingPolicy, err := a.newPolicy(ptype, rule)
+	if err != nil {
 		return err
 	}
-	return nil
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		return a.deleteRecordInTx(ctx, tx, exisingPolicy)
+	})
 }
 
 // RemovePolicies removes policy rules from the storage.
 // This is part of the Auto-Save feature.
 func (a *bunAdapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
-	return a.db.RunInTx(context.Background(), &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
-		for _, rule := range rules {
-			exisingPolicy := newCasbinPolicy(ptype, rule)
-			if err := a.deleteRecordInTx(tx, exisingPolicy); err != nil {
+	return a.RemovePoliciesCtx(context.Background(), sec, ptype, rules)
+}
+
+// RemovePoliciesCtx removes policy rules from the storage with context.
+// This is part of the Auto-Save feature.
+func (a *bunAdapter) RemovePoliciesCtx(ctx context.Context, sec string, ptype string, rules [][]string) (err error) {
+	ctx, op := a.startOperation(ctx, "RemovePolicies", ptypeKey.String(ptype), ruleCountKey.Int(len(rules)))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("rules", rules))
+
+	exisingPolicies, err := a.newPolicies(ptype, rules)
+	if err != nil {
+		return err
+	}
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		for _, exisingPolicy := range exisingPolicies {
+			if err := a.deleteRecordInTx(ctx, tx, exisingPolicy); err != nil {
 				return err
 			}
 		}
@@ -261,36 +569,27 @@ func (a *bunAdapter) RemovePolicies(sec string, ptype string, rules [][]string)
 	})
 }
 
-func (a *bunAdapter) deleteRecord(existingPolicy CasbinPolicy) error {
-	query := a.db.NewDelete().
-		Model((*CasbinPolicy)(nil)).
-		Where("ptype = ?", existingPolicy.PType)
-
-	values := existingPolicy.filterValuesWithKey()
-
-	return a.delete(query, values)
-}
-
-func (a *bunAdapter) deleteRecordInTx(tx bun.Tx, existingPolicy CasbinPolicy) error {
+func (a *bunAdapter) deleteRecordInTx(ctx context.Context, tx bun.Tx, existingPolicy CasbinPolicy) error {
 	query := tx.NewDelete().
-		Model((*CasbinPolicy)(nil)).
-		Where("ptype = ?", existingPolicy.PType)
+		Model(a.tableModel()).
+		Where(a.equalClause("ptype"), existingPolicy.PType)
 
 	values := existingPolicy.filterValuesWithKey()
 
-	return a.delete(query, values)
+	res, err := a.delete(ctx, query, values)
+	if err != nil {
+		return err
+	}
+	return a.checkFound(ctx, tx, res, existingPolicy)
 }
 
-func (a *bunAdapter) delete(query *bun.DeleteQuery, values map[string]string) error {
+func (a *bunAdapter) delete(ctx context.Context, query *bun.DeleteQuery, values map[string]string) (sql.Result, error) {
+	query = scopeQuery(a, query)
 	for key, value := range values {
-		query = query.Where(fmt.Sprintf("%s = ?", key), value)
-	}
-
-	if _, err := query.Exec(context.Background()); err != nil {
-		return err
+		query = query.Where(a.equalClause(key), value)
 	}
 
-	return nil
+	return query.Exec(ctx)
 }
 
 // RemoveFilteredPolicy removes policy rules that match the filter from the storage.
@@ -298,128 +597,130 @@ func (a *bunAdapter) delete(query *bun.DeleteQuery, values map[string]string) er
 // This API is explained in the link below:
 // https://casbin.org/docs/management-api/#removefilteredpolicy
 func (a *bunAdapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
-	if err := a.deleteFilteredPolicy(ptype, fieldIndex, fieldValues...); err != nil {
-		return err
-	}
-	return nil
+	return a.RemoveFilteredPolicyCtx(context.Background(), sec, ptype, fieldIndex, fieldValues...)
 }
 
-func (a *bunAdapter) deleteFilteredPolicy(ptype string, fieldIndex int, fieldValues ...string) error {
-	query := a.db.NewDelete().
-		Model((*CasbinPolicy)(nil)).
-		Where("ptype = ?", ptype)
+// RemoveFilteredPolicyCtx removes policy rules that match the filter from the storage with context.
+// This is part of the Auto-Save feature.
+func (a *bunAdapter) RemoveFilteredPolicyCtx(ctx context.Context, sec string, ptype string, fieldIndex int, fieldValues ...string) (err error) {
+	ctx, op := a.startOperation(ctx, "RemoveFilteredPolicy", ptypeKey.String(ptype))
+	defer func() { op.end(err) }()
+	op.addLogAttrs(slog.Int("field_index", fieldIndex))
+	op.setRuleValues(slog.Any("field_values", fieldValues))
+
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		return a.deleteFilteredPolicy(ctx, tx, ptype, fieldIndex, fieldValues...)
+	})
+}
 
-	// Note that empty string in fieldValues could be any word.
-	if fieldIndex <= 0 && 0 < fieldIndex+len(fieldValues) {
-		value := fieldValues[0-fieldIndex]
-		if value == "" {
-			query = query.Where("v0 LIKE '%'")
-		} else {
-			query = query.Where("v0 = ?", value)
-		}
-	}
-	if fieldIndex <= 1 && 1 < fieldIndex+len(fieldValues) {
-		value := fieldValues[1-fieldIndex]
-		if value == "" {
-			query = query.Where("v1 LIKE '%'")
-		} else {
-			query = query.Where("v1 = ?", value)
-		}
-	}
-	if fieldIndex <= 2 && 2 < fieldIndex+len(fieldValues) {
-		value := fieldValues[2-fieldIndex]
-		if value == "" {
-			query = query.Where("v2 LIKE '%'")
-		} else {
-			query = query.Where("v2 = ?", value)
-		}
-	}
-	if fieldIndex <= 3 && 3 < fieldIndex+len(fieldValues) {
-		value := fieldValues[3-fieldIndex]
-		if value == "" {
-			query = query.Where("v3 LIKE '%'")
-		} else {
-			query = query.Where("v3 = ?", value)
-		}
-	}
-	if fieldIndex <= 4 && 4 < fieldIndex+len(fieldValues) {
-		value := fieldValues[4-fieldIndex]
-		if value == "" {
-			query = query.Where("v4 LIKE '%'")
-		} else {
-			query = query.Where("v4 = ?", value)
-		}
-	}
-	if fieldIndex <= 5 && 5 < fieldIndex+len(fieldValues) {
-		value := fieldValues[5-fieldIndex]
-		if value == "" {
-			query = query.Where("v5 LIKE '%'")
-		} else {
-			query = query.Where("v5 = ?", value)
-		}
-	}
+func (a *bunAdapter) deleteFilteredPolicy(ctx context.Context, db bun.IDB, ptype string, fieldIndex int, fieldValues ...string) error {
+	query := db.NewDelete().
+		Model(a.tableModel()).
+		Where(a.equalClause("ptype"), ptype)
+	query = filterFields(a, scopeQuery(a, query), fieldIndex, fieldValues...)
 
-	if _, err := query.Exec(context.Background()); err != nil {
+	if _, err := query.Exec(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// filterFields restricts the query to the rules whose fields from fieldIndex on equal fieldValues.
+// Note that empty string in fieldValues could be any word.
+func filterFields[Q whereQuery[Q]](a *bunAdapter, query Q, fieldIndex int, fieldValues ...string) Q {
+	for i, column := range ruleColumns[1:] {
+		if fieldIndex <= i && i < fieldIndex+len(fieldValues) {
+			value := fieldValues[i-fieldIndex]
+			if value == "" {
+				query = query.Where(column + " LIKE '%'")
+			} else {
+				query = query.Where(a.equalClause(column), value)
+			}
+		}
+	}
+	return query
+}
+
 // UpdatePolicy updates a policy rule from storage.
 // This is part of the Auto-Save feature.
 func (a *bunAdapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
-	oldPolicy := newCasbinPolicy(ptype, oldRule)
-	newPolicy := newCasbinPolicy(ptype, newRule)
-	return a.updateRecord(oldPolicy, newPolicy)
+	return a.UpdatePolicyCtx(context.Background(), sec, ptype, oldRule, newRule)
 }
 
-func (a *bunAdapter) updateRecord(oldPolicy, newPolicy CasbinPolicy) error {
-	query := a.db.NewUpdate().
-		Model(&newPolicy).
-		Where("ptype = ?", oldPolicy.PType)
-
-	values := oldPolicy.filterValuesWithKey()
+// UpdatePolicyCtx updates a policy rule from storage with context.
+// This is part of the Auto-Save feature.
+func (a *bunAdapter) UpdatePolicyCtx(ctx context.Context, sec string, ptype string, oldRule, newRule []string) (err error) {
+	ctx, op := a.startOperation(ctx, "UpdatePolicy", ptypeKey.String(ptype), ruleCountKey.Int(1))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("old_rule", oldRule), slog.Any("new_rule", newRule))
 
-	return a.update(query, values)
+	oldPolicy, err := a.newPolicy(ptype, oldRule)
+	if err != nil {
+		return err
+	}
+	newPolicy, err := a.newPolicy(ptype, newRule)
+	if err != nil {
+		return err
+	}
+	if err := a.checkWidth(newPolicy); err != nil {
+		return err
+	}
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		return a.updateRecordInTx(ctx, tx, oldPolicy, newPolicy)
+	})
 }
 
-func (a *bunAdapter) updateRecordInTx(tx bun.Tx, oldPolicy, newPolicy CasbinPolicy) error {
+func (a *bunAdapter) updateRecordInTx(ctx context.Context, tx bun.Tx, oldPolicy, newPolicy CasbinPolicy) error {
 	query := tx.NewUpdate().
-		Model(&newPolicy).
-		Where("ptype = ?", oldPolicy.PType)
+		Model(a.toModel(newPolicy)).
+		Where(a.equalClause("ptype"), oldPolicy.PType)
 
 	values := oldPolicy.filterValuesWithKey()
 
-	return a.update(query, values)
+	res, err := a.update(ctx, query, values)
+	if err != nil {
+		return err
+	}
+	return a.checkFound(ctx, tx, res, oldPolicy)
 }
 
-func (a *bunAdapter) update(query *bun.UpdateQuery, values map[string]string) error {
+func (a *bunAdapter) update(ctx context.Context, query *bun.UpdateQuery, values map[string]string) (sql.Result, error) {
+	query = query.Column(ruleColumns...)
+	query = scopeQuery(a, query)
 	for key, value := range values {
-		query = query.Where(fmt.Sprintf("%s = ?", key), value)
+		query = query.Where(a.equalClause(key), value)
 	}
 
-	if _, err := query.Exec(context.Background()); err != nil {
-		return err
-	}
-
-	return nil
+	return query.Exec(ctx)
 }
 
 // UpdatePolicies updates some policy rules to storage, like db, redis.
 func (a *bunAdapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
-	oldPolicies := make([]CasbinPolicy, 0, len(oldRules))
-	newPolicies := make([]CasbinPolicy, 0, len(newRules))
-	for _, rule := range oldRules {
-		oldPolicies = append(oldPolicies, newCasbinPolicy(ptype, rule))
+	return a.UpdatePoliciesCtx(context.Background(), sec, ptype, oldRules, newRules)
+}
+
+// UpdatePoliciesCtx updates some policy rules to storage with context.
+func (a *bunAdapter) UpdatePoliciesCtx(ctx context.Context, sec string, ptype string, oldRules, newRules [][]string) (err error) {
+	ctx, op := a.startOperation(ctx, "UpdatePolicies", ptypeKey.String(ptype), ruleCountKey.Int(len(newRules)))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("old_rules", oldRules), slog.Any("new_rules", newRules))
+
+	oldPolicies, err := a.newPolicies(ptype, oldRules)
+	if err != nil {
+		return err
+	}
+	newPolicies, err := a.newPolicies(ptype, newRules)
+	if err != nil {
+		return err
 	}
-	for _, rule := range newRules {
-		newPolicies = append(newPolicies, newCasbinPolicy(ptype, rule))
+	if err := a.checkWidth(newPolicies...); err != nil {
+		return err
 	}
 
-	return a.db.RunInTx(context.Background(), &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
 		for i := range oldPolicies {
-			if err := a.updateRecordInTx(tx, oldPolicies[i], newPolicies[i]); err != nil {
+			if err := a.updateRecordInTx(ctx, tx, oldPolicies[i], newPolicies[i]); err != nil {
 				return err
 			}
 		}
@@ -429,109 +730,53 @@ func (a *bunAdapter) UpdatePolicies(sec string, ptype string, oldRules, newRules
 
 // UpdateFilteredPolicies deletes old rules and adds new rules.
 func (a *bunAdapter) UpdateFilteredPolicies(sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
-	newPolicies := make([]CasbinPolicy, 0, len(newRules))
-	for _, rule := range newRules {
-		newPolicies = append(newPolicies, newCasbinPolicy(ptype, rule))
-	}
+	return a.UpdateFilteredPoliciesCtx(context.Background(), sec, ptype, newRules, fieldIndex, fieldValues...)
+}
 
-	tx, err := a.db.BeginTx(context.Background(), &sql.TxOptions{})
+// UpdateFilteredPoliciesCtx deletes old rules and adds new rules with context.
+func (a *bunAdapter) UpdateFilteredPoliciesCtx(ctx context.Context, sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) (_ [][]string, err error) {
+	ctx, op := a.startOperation(ctx, "UpdateFilteredPolicies", ptypeKey.String(ptype), ruleCountKey.Int(len(newRules)))
+	defer func() { op.end(err) }()
+	op.addLogAttrs(slog.Int("field_index", fieldIndex))
+	op.setRuleValues(slog.Any("new_rules", newRules), slog.Any("field_values", fieldValues))
+
+	newPolicies, err := a.newPolicies(ptype, newRules)
 	if err != nil {
 		return nil, err
 	}
-
-	oldPolicies := make([]CasbinPolicy, 0)
-	selectQuery := tx.NewSelect().
-		Model(&oldPolicies).
-		Where("ptype = ?", ptype)
-	deleteQuery := tx.NewDelete().
-		Model((*CasbinPolicy)(nil)).
-		Where("ptype = ?", ptype)
-
-	// Note that empty string in fieldValues could be any word.
-	if fieldIndex <= 0 && 0 < fieldIndex+len(fieldValues) {
-		value := fieldValues[0-fieldIndex]
-		if value == "" {
-			selectQuery = selectQuery.Where("v0 LIKE '%'")
-			deleteQuery = deleteQuery.Where("v0 LIKE '%'")
-		} else {
-			selectQuery = selectQuery.Where("v0 = ?", value)
-			deleteQuery = deleteQuery.Where("v0 = ?", value)
-		}
-	}
-	if fieldIndex <= 1 && 1 < fieldIndex+len(fieldValues) {
-		value := fieldValues[1-fieldIndex]
-		if value == "" {
-			selectQuery = selectQuery.Where("v1 LIKE '%'")
-			deleteQuery = deleteQuery.Where("v1 LIKE '%'")
-		} else {
-			selectQuery = selectQuery.Where("v1 = ?", value)
-			deleteQuery = deleteQuery.Where("v1 = ?", value)
-		}
-	}
-	if fieldIndex <= 2 && 2 < fieldIndex+len(fieldValues) {
-		value := fieldValues[2-fieldIndex]
-		if value == "" {
-			selectQuery = selectQuery.Where("v2 LIKE '%'")
-			deleteQuery = deleteQuery.Where("v2 LIKE '%'")
-		} else {
-			selectQuery = selectQuery.Where("v2 = ?", value)
-			deleteQuery = deleteQuery.Where("v2 = ?", value)
-		}
-	}
-	if fieldIndex <= 3 && 3 < fieldIndex+len(fieldValues) {
-		value := fieldValues[3-fieldIndex]
-		if value == "" {
-			selectQuery = selectQuery.Where("v3 LIKE '%'")
-			deleteQuery = deleteQuery.Where("v3 LIKE '%'")
-		} else {
-			selectQuery = selectQuery.Where("v3 = ?", value)
-			deleteQuery = deleteQuery.Where("v3 = ?", value)
-		}
-	}
-	if fieldIndex <= 4 && 4 < fieldIndex+len(fieldValues) {
-		value := fieldValues[4-fieldIndex]
-		if value == "" {
-			selectQuery = selectQuery.Where("v4 LIKE '%'")
-			deleteQuery = deleteQuery.Where("v4 LIKE '%'")
-		} else {
-			selectQuery = selectQuery.Where("v4 = ?", value)
-			deleteQuery = deleteQuery.Where("v4 = ?", value)
-		}
-	}
-	if fieldIndex <= 5 && 5 < fieldIndex+len(fieldValues) {
-		value := fieldValues[5-fieldIndex]
-		if value == "" {
-			selectQuery = selectQuery.Where("v5 LIKE '%'")
-			deleteQuery = deleteQuery.Where("v5 LIKE '%'")
-		} else {
-			selectQuery = selectQuery.Where("v5 = ?", value)
-			deleteQuery = deleteQuery.Where("v5 = ?", value)
-		}
+	if err := a.checkWidth(newPolicies...); err != nil {
+		return nil, err
 	}
 
-	// store old policies
-	if err := selectQuery.Scan(context.Background()); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return nil, err
+	var oldPolicies []CasbinPolicy
+	if err := a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		selectQuery := tx.NewSelect().
+			Model(a.tableModel()).
+			Where(a.equalClause("ptype"), ptype)
+		deleteQuery := tx.NewDelete().
+			Model(a.tableModel()).
+			Where(a.equalClause("ptype"), ptype)
+		selectQuery = filterFields(a, scopeQuery(a, excludeColumns(a, selectQuery)), fieldIndex, fieldValues...)
+		deleteQuery = filterFields(a, scopeQuery(a, deleteQuery), fieldIndex, fieldValues...)
+
+		// store old policies
+		var err error
+		if oldPolicies, err = a.collectPolicies(ctx, selectQuery); err != nil {
+			return err
 		}
-		return nil, err
-	}
 
-	// delete old policies
-	if _, err := deleteQuery.Exec(context.Background()); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return nil, err
+		// delete old policies
+		if _, err := deleteQuery.Exec(ctx); err != nil {
+			return err
 		}
-		return nil, err
-	}
 
-	// create new policies
-	if _, err := tx.NewInsert().
-		Model(&newPolicies).
-		Exec(context.Background()); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return nil, err
+		// create new policies
+		if err := a.insertPolicies(ctx, tx, newPolicies); err != nil {
+			return err
 		}
+
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 
@@ -540,5 +785,5 @@ func (a *bunAdapter) UpdateFilteredPolicies(sec string, ptype string, newRules [
 		out = append(out, policy.toSlice())
 	}
 
-	return out, tx.Commit()
+	return out, nil
 }
@@ -1,11 +1,13 @@
 package casbinbunadapter
 
 import (
+	"database/sql"
 	"testing"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/persist"
 	"github.com/casbin/casbin/v2/util"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func testGetPolicy(t *testing.T, e *casbin.Enforcer, want [][]string) {
@@ -53,6 +55,26 @@ func initAdapter(t *testing.T, driverName, dataSourceName string) persist.Adapte
 	return a
 }
 
+// openSQLite opens a private in-memory SQLite database.
+// The pool is limited to one connection because every connection to ":memory:" sees its own database.
+func openSQLite(t testing.TB) *sql.DB {
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return sqlDB
+}
+
+// newSQLiteAdapter creates an adapter on the SQLite database, which several adapters of a test may share.
+func newSQLiteAdapter(t testing.TB, sqlDB *sql.DB, opts ...Option) Adapter {
+	a, err := NewAdapterWithSqlDB(sqlDB, "sqlite3", opts...)
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	return a
+}
+
 func testSaveLoad(t *testing.T, a persist.Adapter) {
 	initPolicy(t, a)
 
@@ -433,3 +455,26 @@ func TestBunAdapter_UpdateFilteredPolicies(t *testing.T) {
 		},
 	)
 }
+
+func TestBunAdapter_LoadPolicyPageSize(t *testing.T) {
+	for _, size := range []int{1, 2, 5, 10} {
+		a, err := NewAdapterWithSqlDB(openSQLite(t), "sqlite3", WithLoadPageSize(size))
+		if err != nil {
+			t.Fatalf("failed to create adapter: %v", err)
+		}
+		initPolicy(t, a)
+
+		e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+		if err != nil {
+			t.Fatalf("failed to create enforcer: %v", err)
+		}
+		testGetPolicy(
+			t,
+			e,
+			[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		)
+		if ok, _ := e.HasGroupingPolicy("alice", "data2_admin"); !ok {
+			t.Errorf("page size %d: grouping policy is not loaded", size)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+var (
+	// check if the CachedAdapter implements the Adapter interface of this package
+	_ Adapter = (*CachedAdapter)(nil)
+)
+
+// CachedAdapter is a read-through cache in front of an Adapter.
+// It keeps the rules returned by LoadPolicy and LoadFilteredPolicy in memory
+// and serves them again until the TTL expires or a write goes through the CachedAdapter.
+// Writes made by other processes or other adapters are only seen after the TTL.
+type CachedAdapter struct {
+	Adapter
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	generation uint64
+	isFiltered bool
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// cacheEntry is a loaded rule set, grouped by section and ptype.
+type cacheEntry struct {
+	rules    map[string]map[string][][]string
+	loadedAt time.Time
+	// revision is the revision of the rules loaded by LoadPolicy, if the wrapped adapter tracks it.
+	revision    int64
+	hasRevision bool
+}
+
+// unfilteredKey is the cache key of the rule set loaded by LoadPolicy.
+const unfilteredKey = ""
+
+// NewCachedAdapter wraps the adapter with a cache whose entries expire after ttl.
+// A ttl of zero or less keeps the entries until the next write.
+func NewCachedAdapter(adapter Adapter, ttl time.Duration) *CachedAdapter {
+	return &CachedAdapter{
+		Adapter: adapter,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Hits returns how many loads have been served from the cache.
+func (c *CachedAdapter) Hits() uint64 {
+	return c.hits.Load()
+}
+
+// Misses returns how many loads have been passed to the underlying adapter.
+func (c *CachedAdapter) Misses() uint64 {
+	return c.misses.Load()
+}
+
+// Invalidate drops every cached rule set.
+func (c *CachedAdapter) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
+
+// LoadPolicy loads all policy rules from the cache or, on a miss, from the storage.
+func (c *CachedAdapter) LoadPolicy(model model.Model) error {
+	if err := c.load(model, unfilteredKey, c.Adapter.LoadPolicy); err != nil {
+		return err
+	}
+	c.setFiltered(false)
+	return nil
+}
+
+// LoadFilteredPolicy loads the policy rules that match the filter from the cache or, on a miss, from the storage.
+func (c *CachedAdapter) LoadFilteredPolicy(m model.Model, filter interface{}) error {
+	b, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	if err := c.load(m, "filter:"+string(b), func(loaded model.Model) error {
+		return c.Adapter.LoadFilteredPolicy(loaded, filter)
+	}); err != nil {
+		return err
+	}
+	c.setFiltered(true)
+	return nil
+}
+
+// IsFiltered returns true if the loaded policy has been filtered.
+func (c *CachedAdapter) IsFiltered() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.isFiltered
+}
+
+func (c *CachedAdapter) setFiltered(isFiltered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.isFiltered = isFiltered
+	// a cache hit bypasses the wrapped adapter, which must still allow or refuse SavePolicy
+	if b, ok := c.Adapter.(*bunAdapter); ok {
+		b.isFiltered = isFiltered
+	}
+}
+
+func (c *CachedAdapter) load(m model.Model, key string, loadFn func(model.Model) error) error {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if ok && (c.ttl <= 0 || c.now().Sub(entry.loadedAt) < c.ttl) {
+		c.hits.Add(1)
+		// a cache hit bypasses the wrapped adapter, whose SavePolicy must still compare against the cached revision
+		if b, ok := c.Adapter.(*bunAdapter); ok && entry.hasRevision {
+			b.revisions.set(b.tenantID, entry.revision)
+		}
+		return addCachedRules(m, entry.rules)
+	}
+	c.misses.Add(1)
+
+	// load into an empty copy of the model, so that only the stored rules are cached
+	loaded := m.Copy()
+	loaded.ClearPolicy()
+	loadedAt := c.now()
+	if err := loadFn(loaded); err != nil {
+		return err
+	}
+
+	entry = cacheEntry{
+		rules:    make(map[string]map[string][][]string),
+		loadedAt: loadedAt,
+	}
+	if b, ok := c.Adapter.(*bunAdapter); ok && key == unfilteredKey {
+		entry.revision, entry.hasRevision = b.revisions.get(b.tenantID)
+	}
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range loaded[sec] {
+			if len(ast.Policy) == 0 {
+				continue
+			}
+			if entry.rules[sec] == nil {
+				entry.rules[sec] = make(map[string][][]string)
+			}
+			entry.rules[sec][ptype] = ast.Policy
+		}
+	}
+
+	c.mu.Lock()
+	// a write during the load may have made the loaded rules stale
+	if c.generation == generation {
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	return addCachedRules(m, entry.rules)
+}
+
+func addCachedRules(m model.Model, rules map[string]map[string][][]string) error {
+	for sec, ptypes := range rules {
+		for ptype, policies := range ptypes {
+			if err := m.AddPolicies(sec, ptype, policies); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SavePolicy saves all policy rules to the storage and invalidates the cache.
+func (c *CachedAdapter) SavePolicy(model model.Model) error {
+	defer c.Invalidate()
+	return c.Adapter.SavePolicy(model)
+}
+
+// AddPolicy adds a policy rule to the storage and invalidates the cache.
+func (c *CachedAdapter) AddPolicy(sec string, ptype string, rule []string) error {
+	defer c.Invalidate()
+	return c.Adapter.AddPolicy(sec, ptype, rule)
+}
+
+// AddPolicies adds policy rules to the storage and invalidates the cache.
+func (c *CachedAdapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	defer c.Invalidate()
+	return c.Adapter.AddPolicies(sec, ptype, rules)
+}
+
+// RemovePolicy removes a policy rule from the storage and invalidates the cache.
+func (c *CachedAdapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	defer c.Invalidate()
+	return c.Adapter.RemovePolicy(sec, ptype, rule)
+}
+
+// RemovePolicies removes policy rules from the storage and invalidates the cache.
+func (c *CachedAdapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	defer c.Invalidate()
+	return c.Adapter.RemovePolicies(sec, ptype, rules)
+}
+
+// RemoveFilteredPolicy removes policy rules that match the filter from the storage and invalidates the cache.
+func (c *CachedAdapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	defer c.Invalidate()
+	return c.Adapter.RemoveFilteredPolicy(sec, ptype, fieldIndex, fieldValues...)
+}
+
+// UpdatePolicy updates a policy rule in the storage and invalidates the cache.
+func (c *CachedAdapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	defer c.Invalidate()
+	return c.Adapter.UpdatePolicy(sec, ptype, oldRule, newRule)
+}
+
+// UpdatePolicies updates policy rules in the storage and invalidates the cache.
+func (c *CachedAdapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	defer c.Invalidate()
+	return c.Adapter.UpdatePolicies(sec, ptype, oldRules, newRules)
+}
+
+// UpdateFilteredPolicies deletes old rules, adds new rules and invalidates the cache.
+func (c *CachedAdapter) UpdateFilteredPolicies(sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	defer c.Invalidate()
+	return c.Adapter.UpdateFilteredPolicies(sec, ptype, newRules, fieldIndex, fieldValues...)
+}
+
+// Import stores the imported policy rules and invalidates the cache.
+func (c *CachedAdapter) Import(ctx context.Context, r io.Reader, format Format, mode ImportMode) error {
+	defer c.Invalidate()
+	return c.Adapter.Import(ctx, r, format, mode)
+}
+
+// AddPolicyWithExpiry adds a policy rule with an expiry to the storage and invalidates the cache.
+func (c *CachedAdapter) AddPolicyWithExpiry(ctx context.Context, sec string, ptype string, rule []string, validFrom, expiresAt time.Time) error {
+	defer c.Invalidate()
+	return c.Adapter.AddPolicyWithExpiry(ctx, sec, ptype, rule, validFrom, expiresAt)
+}
+
+// Purge deletes the expired policy rules from the storage and invalidates the cache.
+func (c *CachedAdapter) Purge(ctx context.Context) ([]ExpiredPolicy, error) {
+	defer c.Invalidate()
+	return c.Adapter.Purge(ctx)
+}
+
+// Deduplicate deletes the duplicate rows from the storage and invalidates the cache.
+func (c *CachedAdapter) Deduplicate(ctx context.Context) (int64, error) {
+	defer c.Invalidate()
+	return c.Adapter.Deduplicate(ctx)
+}
+
+// AddPoliciesIgnoringDuplicates adds the policy rules that are not stored yet and invalidates the cache.
+func (c *CachedAdapter) AddPoliciesIgnoringDuplicates(ctx context.Context, sec string, ptype string, rules [][]string) ([][]string, error) {
+	defer c.Invalidate()
+	return c.Adapter.AddPoliciesIgnoringDuplicates(ctx, sec, ptype, rules)
+}
+
+// MigrateFrom copies the rules of a table of another layout into the storage and invalidates the cache.
+func (c *CachedAdapter) MigrateFrom(ctx context.Context, layout TableLayout, opts MigrateOptions) (MigrateReport, error) {
+	defer c.Invalidate()
+	return c.Adapter.MigrateFrom(ctx, layout, opts)
+}
@@ -0,0 +1,92 @@
+package casbinbunadapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestCachedAdapter(t *testing.T) {
+	a := newSQLiteAdapter(t, openSQLite(t))
+	initPolicy(t, a)
+	c := NewCachedAdapter(a, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", c)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	want := [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}}
+	testGetPolicy(t, e, want)
+
+	// 1. loads within the TTL are served from the cache
+	if err := a.AddPolicy("p", "p", []string{"jack", "data3", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, want)
+	if ok, _ := e.Enforce("alice", "data2", "read"); !ok {
+		t.Error("cached grouping policy is not applied")
+	}
+	if c.Hits() != 1 || c.Misses() != 1 {
+		t.Errorf("got %d hits and %d misses, want 1 and 1", c.Hits(), c.Misses())
+	}
+
+	// 2. the cache expires after the TTL
+	now = now.Add(time.Minute)
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	want = append(want, []string{"jack", "data3", "read"})
+	testGetPolicy(t, e, want)
+	if c.Misses() != 2 {
+		t.Errorf("got %d misses, want 2", c.Misses())
+	}
+
+	// 3. writes through the cached adapter invalidate the cache
+	if _, err := e.RemovePolicy("jack", "data3", "read"); err != nil {
+		t.Fatalf("failed to remove policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, want[:4])
+	if c.Hits() != 1 || c.Misses() != 3 {
+		t.Errorf("got %d hits and %d misses, want 1 and 3", c.Hits(), c.Misses())
+	}
+}
+
+func TestCachedAdapter_LoadFilteredPolicy(t *testing.T) {
+	a := newSQLiteAdapter(t, openSQLite(t))
+	initPolicy(t, a)
+	c := NewCachedAdapter(a, 0)
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	e.SetAdapter(c)
+
+	for i := 0; i < 2; i++ {
+		if err := e.LoadFilteredPolicy(&Filter{PType: []string{"p"}, V0: []string{"bob", "data2_admin"}}); err != nil {
+			t.Fatalf("failed to load filtered policy: %v", err)
+		}
+		testGetPolicy(t, e, [][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+	}
+	if !c.IsFiltered() {
+		t.Error("IsFiltered() = false, want true")
+	}
+
+	if err := e.LoadFilteredPolicy(Filter{V1: []string{"data1"}}); err != nil {
+		t.Fatalf("failed to load filtered policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}})
+
+	if c.Hits() != 1 || c.Misses() != 2 {
+		t.Errorf("got %d hits and %d misses, want 1 and 2", c.Hits(), c.Misses())
+	}
+}
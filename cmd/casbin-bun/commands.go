@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	casbinbunadapter "github.com/devoteclick/casbin-bun-adapter"
+)
+
+// runMigrate upgrades the table that the constructor found or created, and fails when a column needs a manual migration.
+func runMigrate(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	ctx := context.Background()
+	diffs, err := a.InspectSchema(ctx)
+	if err != nil {
+		return err
+	}
+	unsafe, err := a.UpgradeSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		if diff.Safe {
+			fmt.Fprintf(stdout, "upgraded %s\n", diff)
+		}
+	}
+	for _, diff := range unsafe {
+		fmt.Fprintf(stdout, "needs a manual migration: %s\n", diff)
+	}
+	if len(unsafe) > 0 {
+		return fmt.Errorf("%d columns need a manual migration", len(unsafe))
+	}
+	fmt.Fprintln(stdout, "the policy table is up to date")
+	return nil
+}
+
+func runExport(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "output format: csv, json or yaml")
+	output := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return a.Export(context.Background(), w, casbinbunadapter.Format(*format))
+}
+
+func runImport(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "csv", "input format: csv, json or yaml")
+	mode := fs.String("mode", "merge", "import mode: replace or merge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r := io.Reader(os.Stdin)
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return a.Import(context.Background(), r, casbinbunadapter.Format(*format), casbinbunadapter.ImportMode(*mode))
+}
+
+// listPageSize is the number of rules that list reads from the database at a time.
+const listPageSize = 1000
+
+// runList prints the rules of the ptype that match the field filter, which the database applies.
+func runList(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	ptype := fs.String("ptype", "p", "ptype of the rules")
+	fieldIndex := fs.Int("field-index", 0, "index of the first field value")
+	count := fs.Bool("count", false, "print the number of rules instead of the rules")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter := casbinbunadapter.PolicyFilter{FieldIndex: *fieldIndex, FieldValues: fs.Args(), Limit: listPageSize}
+
+	ctx := context.Background()
+	if *count {
+		n, err := a.CountPolicies(ctx, *ptype, filter)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, n)
+		return nil
+	}
+	for {
+		rules, cursor, err := a.FindPolicies(ctx, *ptype, filter)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			fmt.Fprintln(stdout, formatRule(append([]string{*ptype}, rule...)))
+		}
+		if cursor == "" {
+			return nil
+		}
+		filter.Cursor = cursor
+	}
+}
+
+func runAdd(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	ptype, rule, err := parseRuleArgs("add", args)
+	if err != nil {
+		return err
+	}
+	return a.AddPolicy(ptype[:1], ptype, rule)
+}
+
+func runRemove(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	ptype, rule, err := parseRuleArgs("remove", args)
+	if err != nil {
+		return err
+	}
+	return a.RemovePolicy(ptype[:1], ptype, rule)
+}
+
+func runDiff(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: diff file.csv")
+	}
+	_, _, err := diffPolicyFile(a, args[0], stdout)
+	return err
+}
+
+func runSave(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("save", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the changes without saving them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: save [-dry-run] file.csv")
+	}
+
+	added, removed, err := diffPolicyFile(a, fs.Arg(0), stdout)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(stdout, "dry run: %d rules would be added, %d rules would be removed\n", added, removed)
+		return nil
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := a.Import(context.Background(), f, casbinbunadapter.FormatCSV, casbinbunadapter.ImportReplace); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d rules added, %d rules removed\n", added, removed)
+	return nil
+}
+
+func runMigrateFrom(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("migrate-from", flag.ContinueOnError)
+	from := fs.String("from", "gorm", "layout of the source table: gorm or xorm")
+	table := fs.String("table", "", "name of the source table (default casbin_rule)")
+	verify := fs.Bool("verify", false, "compare the row counts and content hashes after copying")
+	dryRun := fs.Bool("dry-run", false, "print what would be copied without copying")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var layout casbinbunadapter.TableLayout
+	switch *from {
+	case "gorm":
+		layout = casbinbunadapter.GormAdapterLayout
+	case "xorm":
+		layout = casbinbunadapter.XormAdapterLayout
+	default:
+		return fmt.Errorf("unknown source layout: %s", *from)
+	}
+	if *table != "" {
+		layout.Table = *table
+	}
+
+	report, err := a.MigrateFrom(context.Background(), layout, casbinbunadapter.MigrateOptions{DryRun: *dryRun, Verify: *verify})
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(stdout, "dry run: %d rules would be copied, %d rules are already stored\n", report.Copied, report.Skipped)
+	} else {
+		fmt.Fprintf(stdout, "%d rules copied, %d rules already stored\n", report.Copied, report.Skipped)
+	}
+	if report.Verified {
+		fmt.Fprintln(stdout, "verified: casbin_policies matches the source table")
+	}
+	return nil
+}
+
+func runDedupe(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error {
+	deleted, err := a.Deduplicate(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d duplicate rows deleted\n", deleted)
+	return nil
+}
+
+func parseRuleArgs(name string, args []string) (string, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	ptype := fs.String("ptype", "p", "ptype of the rule")
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	if *ptype == "" || fs.NArg() == 0 {
+		return "", nil, fmt.Errorf("usage: %s -ptype ptype values...", name)
+	}
+	return *ptype, fs.Args(), nil
+}
+
+// diffPolicyFile prints the rules that are only in the file with "+" and the rules that are only stored with "-".
+func diffPolicyFile(a casbinbunadapter.Adapter, path string, stdout io.Writer) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	fileRules, err := readRules(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var storedRules [][]string
+	if err := forEachStoredRule(a, func(record []string) error {
+		storedRules = append(storedRules, record)
+		return nil
+	}); err != nil {
+		return 0, 0, err
+	}
+
+	remaining := make(map[string]int)
+	for _, record := range storedRules {
+		remaining[formatRule(record)]++
+	}
+	added := 0
+	for _, record := range fileRules {
+		key := formatRule(record)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		fmt.Fprintf(stdout, "+ %s\n", key)
+		added++
+	}
+	removed := 0
+	for _, record := range storedRules {
+		key := formatRule(record)
+		if remaining[key] > 0 {
+			remaining[key]--
+			fmt.Fprintf(stdout, "- %s\n", key)
+			removed++
+		}
+	}
+
+	return added, removed, nil
+}
+
+// forEachStoredRule streams the stored rules through the CSV export.
+// Each record starts with the ptype.
+func forEachStoredRule(a casbinbunadapter.Adapter, fn func(record []string) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(a.Export(context.Background(), pw, casbinbunadapter.FormatCSV))
+	}()
+	defer pr.Close()
+
+	reader := newRuleReader(pr)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(trimRule(record)); err != nil {
+			return err
+		}
+	}
+}
+
+func readRules(r io.Reader) ([][]string, error) {
+	records, err := newRuleReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		records[i] = trimRule(records[i])
+	}
+	return records, nil
+}
+
+// newRuleReader reads the Casbin policy file format, the same way the adapter imports it.
+func newRuleReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// trimRule drops trailing empty values, which the adapter does not store.
+func trimRule(record []string) []string {
+	for len(record) > 1 && record[len(record)-1] == "" {
+		record = record[:len(record)-1]
+	}
+	return record
+}
+
+func formatRule(record []string) string {
+	return strings.Join(record, ", ")
+}
@@ -0,0 +1,130 @@
+// Command casbin-bun manages the policy rules stored by casbin-bun-adapter.
+//
+// Usage:
+//
+//	casbin-bun -driver <driver> -dsn <dsn> [-tenant <tenant>] [-namespace <namespace>] <command> [flags] [args]
+//
+// The commands are:
+//
+//	migrate   create the casbin_policies table if it does not exist, or upgrade an older one
+//	export    write the stored rules as CSV, JSON or YAML
+//	import    read rules from CSV, JSON or YAML and store them
+//	list      print the stored rules that match a ptype and field filter
+//	add       add a rule
+//	remove    remove a rule
+//	diff      compare the stored rules with a Casbin policy file
+//	save      replace the stored rules with a Casbin policy file, like SavePolicy
+//	migrate-from
+//	          copy the rules of a gorm-adapter or xorm-adapter table into casbin_policies
+//
+// The supported drivers are mysql, postgres, mssql and sqlite3.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	casbinbunadapter "github.com/devoteclick/casbin-bun-adapter"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(a casbinbunadapter.Adapter, args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{name: "migrate", usage: "migrate", run: runMigrate},
+	{name: "export", usage: "export [-format csv|json|yaml] [-o file]", run: runExport},
+	{name: "import", usage: "import [-format csv|json|yaml] [-mode replace|merge] [file]", run: runImport},
+	{name: "list", usage: "list [-ptype ptype] [-field-index index] [-count] [field values...]", run: runList},
+	{name: "add", usage: "add -ptype ptype values...", run: runAdd},
+	{name: "remove", usage: "remove -ptype ptype values...", run: runRemove},
+	{name: "diff", usage: "diff file.csv", run: runDiff},
+	{name: "save", usage: "save [-dry-run] file.csv", run: runSave},
+	{name: "migrate-from", usage: "migrate-from [-from gorm|xorm] [-table table] [-verify] [-dry-run]", run: runMigrateFrom},
+	{name: "dedupe", usage: "dedupe", run: runDedupe},
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "casbin-bun: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("casbin-bun", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	driverName := fs.String("driver", "", "database driver: mysql, postgres, mssql or sqlite3")
+	dataSourceName := fs.String("dsn", "", "data source name")
+	tenantID := fs.String("tenant", "", "scope every command to the tenant")
+	namespace := fs.String("namespace", "", "scope every command to the namespace")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: casbin-bun -driver <driver> -dsn <dsn> [-tenant <tenant>] [-namespace <namespace>] <command> [flags] [args]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, c := range commands {
+			fmt.Fprintf(stderr, "  %s\n", c.usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+	if *driverName == "" || *dataSourceName == "" {
+		return errors.New("-driver and -dsn are required")
+	}
+
+	name := fs.Arg(0)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		var opts []casbinbunadapter.Option
+		if *tenantID != "" {
+			opts = append(opts, casbinbunadapter.WithTenant(*tenantID))
+		}
+		if *namespace != "" {
+			opts = append(opts, casbinbunadapter.WithNamespace(*namespace))
+		}
+		a, err := openAdapter(*driverName, *dataSourceName, opts...)
+		if err != nil {
+			return err
+		}
+		return c.run(a, fs.Args()[1:], stdout)
+	}
+
+	fs.Usage()
+	return fmt.Errorf("unknown command: %s", name)
+}
+
+// openAdapter opens the adapter, registering the drivers that the adapter package leaves to the caller.
+func openAdapter(driverName, dataSourceName string, opts ...casbinbunadapter.Option) (casbinbunadapter.Adapter, error) {
+	var sqlDB *sql.DB
+	switch driverName {
+	case "postgres":
+		sqlDB = sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dataSourceName)))
+	case "sqlite3":
+		db, err := sql.Open("sqlite3", dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows one writer at a time
+		db.SetMaxOpenConns(1)
+		sqlDB = db
+	default:
+		return casbinbunadapter.NewAdapter(driverName, dataSourceName, opts...)
+	}
+	return casbinbunadapter.NewAdapterWithSqlDB(sqlDB, driverName, opts...)
+}
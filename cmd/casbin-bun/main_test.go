@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, dsn string, args ...string) string {
+	var stdout bytes.Buffer
+	if err := run(append([]string{"-driver", "sqlite3", "-dsn", dsn}, args...), &stdout, io.Discard); err != nil {
+		t.Fatalf("%v failed: %v", args, err)
+	}
+	return stdout.String()
+}
+
+func TestRun(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "policies.db")
+	policyFile := "../../testdata/rbac_policy.csv"
+
+	runCommand(t, dsn, "migrate")
+	if got := runCommand(t, dsn, "save", "-dry-run", policyFile); got != "+ p, alice, data1, read\n+ p, bob, data2, write\n+ p, data2_admin, data2, read\n+ p, data2_admin, data2, write\n+ g, alice, data2_admin\ndry run: 5 rules would be added, 0 rules would be removed\n" {
+		t.Errorf("unexpected dry run output: %q", got)
+	}
+	if got := runCommand(t, dsn, "export"); got != "" {
+		t.Errorf("dry run must not save rules, got %q", got)
+	}
+
+	runCommand(t, dsn, "import", "-mode", "replace", policyFile)
+	if got := runCommand(t, dsn, "diff", policyFile); got != "" {
+		t.Errorf("expected no difference, got %q", got)
+	}
+
+	runCommand(t, dsn, "add", "-ptype", "p", "jack", "data2", "read")
+	runCommand(t, dsn, "remove", "-ptype", "g", "alice", "data2_admin")
+	if got := runCommand(t, dsn, "diff", policyFile); got != "+ g, alice, data2_admin\n- p, jack, data2, read\n" {
+		t.Errorf("unexpected diff output: %q", got)
+	}
+
+	if got := runCommand(t, dsn, "list", "-ptype", "p", "-field-index", "1", "data2", "read"); got != "p, data2_admin, data2, read\np, jack, data2, read\n" {
+		t.Errorf("unexpected list output: %q", got)
+	}
+	if got := runCommand(t, dsn, "list", "-count", "-field-index", "1", "data2"); got != "4\n" {
+		t.Errorf("unexpected list count: %q", got)
+	}
+	if got := runCommand(t, dsn, "list", "-ptype", "g"); got != "" {
+		t.Errorf("unexpected list output: %q", got)
+	}
+
+	runCommand(t, dsn, "save", policyFile)
+	if got := runCommand(t, dsn, "diff", policyFile); got != "" {
+		t.Errorf("expected no difference after save, got %q", got)
+	}
+
+	runCommand(t, dsn, "add", "-ptype", "p", "alice", "data1", "read")
+	if got := runCommand(t, dsn, "dedupe"); got != "1 duplicate rows deleted\n" {
+		t.Errorf("unexpected dedupe output: %q", got)
+	}
+}
+
+func TestRun_MigrateFrom(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "policies.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE casbin_rule (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ptype varchar(100),
+		v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100), v4 varchar(100), v5 varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO casbin_rule (ptype, v0, v1, v2) VALUES ('p', 'alice', 'data1', 'read'), ('g', 'alice', 'data2_admin', '')`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := runCommand(t, dsn, "migrate-from", "-dry-run", "-verify"); got != "dry run: 2 rules would be copied, 0 rules are already stored\nverified: casbin_policies matches the source table\n" {
+		t.Errorf("unexpected dry run output: %q", got)
+	}
+	if got := runCommand(t, dsn, "migrate-from", "-verify"); got != "2 rules copied, 0 rules already stored\nverified: casbin_policies matches the source table\n" {
+		t.Errorf("unexpected migrate-from output: %q", got)
+	}
+	if got := runCommand(t, dsn, "migrate-from"); got != "0 rules copied, 2 rules already stored\n" {
+		t.Errorf("a second run must copy nothing, got %q", got)
+	}
+	if got := runCommand(t, dsn, "export"); got != "p, alice, data1, read\ng, alice, data2_admin\n" {
+		t.Errorf("unexpected export output: %q", got)
+	}
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "policies.db")
+	if err := run([]string{"-driver", "sqlite3", "-dsn", dsn, "unknown"}, io.Discard, io.Discard); err == nil {
+		t.Fatal("expected an error for an unknown command")
+	}
+}
+
+func TestRun_Migrate(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "policies.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE casbin_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ptype varchar(100) NOT NULL,
+		v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100), v4 varchar(100), v5 varchar(100),
+		tenant_id varchar(100), namespace varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := runCommand(t, dsn, "migrate"); got != "upgraded valid_from: missing, want TIMESTAMP\nupgraded expires_at: missing, want TIMESTAMP\nthe policy table is up to date\n" {
+		t.Errorf("unexpected migrate output: %q", got)
+	}
+	if got := runCommand(t, dsn, "migrate"); got != "the policy table is up to date\n" {
+		t.Errorf("a second run must change nothing, got %q", got)
+	}
+
+	// a missing column that is NOT NULL cannot be added to the rows
+	if _, err := db.Exec(`DROP TABLE casbin_policies`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE casbin_policies (id INTEGER PRIMARY KEY AUTOINCREMENT, v0 varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	if err := run([]string{"-driver", "sqlite3", "-dsn", dsn, "migrate"}, &stdout, io.Discard); err == nil {
+		t.Error("expected an error for a column that needs a manual migration")
+	}
+	if got := stdout.String(); !strings.Contains(got, "needs a manual migration: ptype: missing, want varchar(100)") {
+		t.Errorf("unexpected migrate output: %q", got)
+	}
+}
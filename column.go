@@ -0,0 +1,168 @@
+package casbinbunadapter
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+// DefaultColumnWidth is the width of the ptype and v0 to v5 columns unless WithColumnWidth is given.
+const DefaultColumnWidth = 100
+
+// tenantColumnWidth is the width of the tenant_id column.
+const tenantColumnWidth = 100
+
+// namespaceColumnWidth is the width of the namespace column.
+const namespaceColumnWidth = 100
+
+// ruleColumns are the columns that hold the values of a policy rule.
+var ruleColumns = []string{"ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+// columnTypeMu serializes the changes that createTable makes to the shared table schema of a bun.DB.
+var columnTypeMu sync.Mutex
+
+// WithColumnWidth sets the width, in characters, of the ptype and v0 to v5 columns.
+// createTable creates the columns with that width, and every insert and update
+// is refused with a *RuleTooLongError when a value is longer than width.
+// A width of 0 or less creates unlimited TEXT columns, NVARCHAR(MAX) on MSSQL, and disables the check.
+// The width only affects tables that the adapter creates, so it must match the width of an existing table.
+func WithColumnWidth(width int) Option {
+	return func(a *bunAdapter) {
+		a.columnWidth = width
+	}
+}
+
+// Collations that compare the bytes of the values, so that "Alice" and "alice" differ.
+const (
+	mysqlBinaryCollation  = "utf8mb4_bin"
+	mssqlBinaryCollation  = "Latin1_General_100_BIN2"
+	sqliteBinaryCollation = "BINARY"
+)
+
+// WithCaseSensitive makes the adapter tell apart values that differ only in case
+// on MySQL and MSSQL, whose default collations are case-insensitive.
+// createTable gives the columns a binary collation, and the WHERE clauses of the removes and updates
+// compare with a binary collation, so tables created with a case-insensitive collation are matched exactly too.
+// Postgres always compares case-sensitively, and so does SQLite unless the columns were declared COLLATE NOCASE.
+func WithCaseSensitive(caseSensitive bool) Option {
+	return func(a *bunAdapter) {
+		a.caseSensitive = caseSensitive
+	}
+}
+
+// equalClause returns the WHERE clause that compares column with a value,
+// with a binary collation if the adapter is case-sensitive.
+func (a *bunAdapter) equalClause(column string) string {
+	return a.collated(column) + " = ?"
+}
+
+// collated returns column with a binary collation if the adapter is case-sensitive.
+// On MySQL the column is converted to utf8mb4 first, since utf8mb4_bin fails on the latin1 and utf8mb3
+// columns of tables that the adapter did not create.
+func (a *bunAdapter) collated(column string) string {
+	if a.caseSensitive {
+		switch a.db.Dialect().Name() {
+		case dialect.MySQL:
+			return "CONVERT(" + column + " USING utf8mb4) COLLATE " + mysqlBinaryCollation
+		case dialect.MSSQL:
+			return column + " COLLATE " + mssqlBinaryCollation
+		case dialect.SQLite:
+			return column + " COLLATE " + sqliteBinaryCollation
+		}
+	}
+	return column
+}
+
+// columnSQLType returns the type of a text column of the given width, unlimited for 0 or less,
+// that stores any Unicode text on the adapter's dialect:
+// NVARCHAR on MSSQL, whose VARCHAR uses the code page of the collation,
+// and an explicit utf8mb4 charset on MySQL, whose tables may default to a legacy charset.
+// Postgres and SQLite store text as UTF-8.
+func (a *bunAdapter) columnSQLType(width int) string {
+	switch a.db.Dialect().Name() {
+	case dialect.MSSQL:
+		sqlType := "nvarchar(max)"
+		if width > 0 {
+			sqlType = fmt.Sprintf("nvarchar(%d)", width)
+		}
+		if a.caseSensitive {
+			sqlType += " COLLATE " + mssqlBinaryCollation
+		}
+		return sqlType
+	case dialect.MySQL:
+		sqlType := "text"
+		if width > 0 {
+			sqlType = fmt.Sprintf("varchar(%d)", width)
+		}
+		collation := "utf8mb4_unicode_ci"
+		if a.caseSensitive {
+			collation = mysqlBinaryCollation
+		}
+		return sqlType + " CHARACTER SET utf8mb4 COLLATE " + collation
+	default:
+		if width > 0 {
+			return fmt.Sprintf("varchar(%d)", width)
+		}
+		return "text"
+	}
+}
+
+// setColumnTypes sets the type of the text columns in the CREATE TABLE statements of the adapter's database.
+// It must be called with columnTypeMu held.
+func (a *bunAdapter) setColumnTypes() {
+	table := a.modelTable()
+	for _, column := range ruleColumns {
+		table.FieldMap[column].CreateTableSQLType = a.columnSQLType(a.columnWidth)
+	}
+	if field, ok := table.FieldMap[tenantColumn]; ok {
+		field.CreateTableSQLType = a.columnSQLType(tenantColumnWidth)
+	}
+	if field, ok := table.FieldMap[namespaceColumn]; ok {
+		field.CreateTableSQLType = a.columnSQLType(namespaceColumnWidth)
+	}
+}
+
+// valueLength returns the length of value as the columns of the adapter's database count it:
+// in UTF-16 code units on MSSQL, whose nvarchar stores a character outside the BMP, like an emoji, as two,
+// and in characters elsewhere.
+func (a *bunAdapter) valueLength(value string) int {
+	if a.db.Dialect().Name() == dialect.MSSQL {
+		return len(utf16.Encode([]rune(value)))
+	}
+	return utf8.RuneCountInString(value)
+}
+
+// checkScopeWidth returns a *RuleTooLongError for a tenant or a namespace of the adapter that does not fit in its column.
+func (a *bunAdapter) checkScopeWidth() error {
+	if length := a.valueLength(a.tenantID); length > tenantColumnWidth {
+		return &RuleTooLongError{Field: tenantColumn, Length: length, Width: tenantColumnWidth}
+	}
+	if length := a.valueLength(a.namespace); length > namespaceColumnWidth {
+		return &RuleTooLongError{Field: namespaceColumn, Length: length, Width: namespaceColumnWidth}
+	}
+	return nil
+}
+
+// checkWidth returns a *RuleTooLongError for the first value of the policies, or the tenant or the namespace
+// they are written to, that does not fit in its column.
+func (a *bunAdapter) checkWidth(policies ...CasbinPolicy) error {
+	// the tenant of the context adapter comes with each call
+	if err := a.checkScopeWidth(); err != nil {
+		return err
+	}
+	if a.columnWidth <= 0 {
+		return nil
+	}
+	for _, policy := range policies {
+		values := []string{policy.PType, policy.V0, policy.V1, policy.V2, policy.V3, policy.V4, policy.V5}
+		for i, value := range values {
+			if length := a.valueLength(value); length > a.columnWidth {
+				return &RuleTooLongError{Field: ruleColumns[i], Length: length, Width: a.columnWidth}
+			}
+		}
+	}
+	return nil
+}
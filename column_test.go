@@ -0,0 +1,345 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mssqldialect"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+)
+
+func tableSQL(t *testing.T, a Adapter) string {
+	var sql string
+	if err := a.(*bunAdapter).db.NewRaw("SELECT sql FROM sqlite_master WHERE name = ?", "casbin_policies").
+		Scan(context.Background(), &sql); err != nil {
+		t.Fatalf("failed to read the table definition: %v", err)
+	}
+	return sql
+}
+
+func TestBunAdapter_ColumnWidth(t *testing.T) {
+	long := strings.Repeat("ü", DefaultColumnWidth+1)
+
+	// 1. the default width
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if got := tableSQL(t, a); !strings.Contains(got, `"v0" varchar(100)`) {
+		t.Errorf("got table %s, want varchar(100) columns", got)
+	}
+	if err := a.AddPolicy("p", "p", []string{strings.Repeat("ü", DefaultColumnWidth), "data1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	err := a.AddPolicy("p", "p", []string{"alice", long, "read"})
+	var tooLong *RuleTooLongError
+	if !errors.As(err, &tooLong) || !errors.Is(err, ErrRuleTooLong) {
+		t.Fatalf("got %v, want a *RuleTooLongError", err)
+	}
+	if tooLong.Field != "v1" || tooLong.Length != DefaultColumnWidth+1 || tooLong.Width != DefaultColumnWidth {
+		t.Errorf("got %+v, want v1 with %d characters", tooLong, DefaultColumnWidth+1)
+	}
+	if err := a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", long}); !errors.Is(err, ErrRuleTooLong) {
+		t.Errorf("got %v, want ErrRuleTooLong", err)
+	}
+	if err := a.AddPolicies("p", long, [][]string{{"alice"}}); !errors.Is(err, ErrRuleTooLong) || !strings.Contains(err.Error(), "ptype") {
+		t.Errorf("got %v, want ErrRuleTooLong for ptype", err)
+	}
+
+	// 2. a custom width
+	a = newSQLiteAdapter(t, openSQLite(t), WithColumnWidth(10))
+	if got := tableSQL(t, a); !strings.Contains(got, `"v5" varchar(10)`) {
+		t.Errorf("got table %s, want varchar(10) columns", got)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read", "", "", "0123456789x"}); !errors.Is(err, ErrRuleTooLong) || !strings.Contains(err.Error(), "v5") {
+		t.Errorf("got %v, want ErrRuleTooLong for v5", err)
+	}
+
+	// 3. unlimited columns
+	a = newSQLiteAdapter(t, openSQLite(t), WithColumnWidth(0))
+	if got := tableSQL(t, a); !strings.Contains(got, `"v0" text`) {
+		t.Errorf("got table %s, want text columns", got)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", long, "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	var buf strings.Builder
+	if err := a.Export(context.Background(), &buf, FormatCSV); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if !strings.Contains(buf.String(), long) {
+		t.Errorf("got %q, want the long value", buf.String())
+	}
+}
+
+func TestBunAdapter_checkWidth(t *testing.T) {
+	// "📁 reports" has 9 characters, and 10 UTF-16 code units since the emoji is outside the BMP
+	policy := newCasbinPolicy("p", []string{"📁 reports"})
+	tests := []struct {
+		name       string
+		dialect    schema.Dialect
+		wantLength int
+	}{
+		{name: "mssql", dialect: mssqldialect.New(), wantLength: 10},
+		{name: "mysql", dialect: mysqldialect.New()},
+		{name: "pg", dialect: pgdialect.New()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &bunAdapter{db: bun.NewDB(openSQLite(t), tt.dialect), columnWidth: 9}
+			err := a.checkWidth(policy)
+			var tooLong *RuleTooLongError
+			switch {
+			case tt.wantLength == 0 && err != nil:
+				t.Errorf("got %v, want the value to fit", err)
+			case tt.wantLength != 0 && (!errors.As(err, &tooLong) || tooLong.Length != tt.wantLength):
+				t.Errorf("got %v, want a *RuleTooLongError of length %d", err, tt.wantLength)
+			}
+		})
+	}
+}
+
+func TestBunAdapter_ScopeWidth(t *testing.T) {
+	long := strings.Repeat("t", tenantColumnWidth+1)
+	tests := []struct {
+		name      string
+		opt       Option
+		wantField string
+	}{
+		{name: "tenant", opt: WithTenant(long), wantField: tenantColumn},
+		{name: "namespace", opt: WithNamespace(long), wantField: namespaceColumn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAdapterWithSqlDB(openSQLite(t), "sqlite3", tt.opt)
+			var tooLong *RuleTooLongError
+			if !errors.Is(err, ErrRuleTooLong) || !errors.As(err, &tooLong) || tooLong.Field != tt.wantField {
+				t.Errorf("got %v, want a *RuleTooLongError for %s", err, tt.wantField)
+			}
+		})
+	}
+
+	t.Run("context", func(t *testing.T) {
+		ca := &ctxBunAdapter{Adapter: newSQLiteAdapter(t, openSQLite(t))}
+		ctx := ContextWithTenant(context.Background(), long)
+		err := ca.AddPolicyCtx(ctx, "p", "p", []string{"bob", "data1", "read"})
+		if !errors.Is(err, ErrRuleTooLong) {
+			t.Errorf("got %v, want ErrRuleTooLong", err)
+		}
+	})
+}
+
+func TestBunAdapter_setColumnTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect schema.Dialect
+		width   int
+		want    []string
+	}{
+		{
+			name:    "mysql",
+			dialect: mysqldialect.New(),
+			width:   DefaultColumnWidth,
+			want: []string{
+				"`v0` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci",
+				"`tenant_id` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci",
+			},
+		},
+		{
+			name:    "mysql unlimited",
+			dialect: mysqldialect.New(),
+			width:   0,
+			want:    []string{"`v0` text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci"},
+		},
+		{
+			name:    "mssql",
+			dialect: mssqldialect.New(),
+			width:   DefaultColumnWidth,
+			want:    []string{`"ptype" nvarchar(100) NOT NULL`, `"v0" nvarchar(100)`, `"tenant_id" nvarchar(100)`},
+		},
+		{
+			name:    "mssql unlimited",
+			dialect: mssqldialect.New(),
+			width:   0,
+			want:    []string{`"v0" nvarchar(max)`},
+		},
+		{
+			name:    "postgres",
+			dialect: pgdialect.New(),
+			width:   DefaultColumnWidth,
+			want:    []string{`"v0" varchar(100)`, `"tenant_id" varchar(100)`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &bunAdapter{db: bun.NewDB(openSQLite(t), tt.dialect), modelType: casbinPolicyType, columnWidth: tt.width}
+			columnTypeMu.Lock()
+			a.setColumnTypes()
+			columnTypeMu.Unlock()
+
+			got := a.db.NewCreateTable().Model((*CasbinPolicy)(nil)).String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("got %s, want it to contain %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBunAdapter_equalClause(t *testing.T) {
+	tests := []struct {
+		name          string
+		dialect       schema.Dialect
+		caseSensitive bool
+		where         string
+	}{
+		{name: "mysql", dialect: mysqldialect.New(), where: "WHERE (v0 = 'Alice')"},
+		{name: "mysql case-sensitive", dialect: mysqldialect.New(), caseSensitive: true, where: "WHERE (CONVERT(v0 USING utf8mb4) COLLATE utf8mb4_bin = 'Alice')"},
+		{name: "mssql case-sensitive", dialect: mssqldialect.New(), caseSensitive: true, where: "WHERE (v0 COLLATE Latin1_General_100_BIN2 = N'Alice')"},
+		{name: "postgres case-sensitive", dialect: pgdialect.New(), caseSensitive: true, where: "WHERE (v0 = 'Alice')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &bunAdapter{db: bun.NewDB(openSQLite(t), tt.dialect), modelType: casbinPolicyType, caseSensitive: tt.caseSensitive}
+			got := a.db.NewDelete().Model((*CasbinPolicy)(nil)).Where(a.equalClause("v0"), "Alice").String()
+			if !strings.HasSuffix(got, tt.where) {
+				t.Errorf("got %s, want it to end with %s", got, tt.where)
+			}
+		})
+	}
+}
+
+func TestBunAdapter_Unicode(t *testing.T) {
+	rules := [][]string{
+		{"アリス", "データ1", "読む"},
+		{"bob", "📁 reports", "✍️"},
+		{"zoë", "café", "read"},
+		{"محمد", "数据", "写"},
+	}
+
+	// the width counts characters, not bytes
+	a := newSQLiteAdapter(t, openSQLite(t), WithColumnWidth(9))
+	if err := a.AddPolicies("p", "p", rules); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+
+	// 1. the rules are loaded as they were written
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(t, e, rules)
+
+	// 2. updates and removes match the rules by their exact values
+	if _, err := e.UpdatePolicy([]string{"zoë", "café", "read"}, []string{"zoë", "café", "écrire"}); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if _, err := e.RemovePolicy("bob", "📁 reports", "✍️"); err != nil {
+		t.Fatalf("failed to remove policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"アリス", "データ1", "読む"}, {"zoë", "café", "écrire"}, {"محمد", "数据", "写"}})
+
+	// 3. the filtered load and the export see the same values
+	e.ClearPolicy()
+	if err := a.LoadFilteredPolicy(e.GetModel(), Filter{V0: []string{"アリス"}}); err != nil {
+		t.Fatalf("failed to load filtered policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"アリス", "データ1", "読む"}})
+
+	var buf strings.Builder
+	if err := a.Export(context.Background(), &buf, FormatJSON); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	dst := newSQLiteAdapter(t, openSQLite(t))
+	if err := dst.Import(context.Background(), strings.NewReader(buf.String()), FormatJSON, ImportReplace); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	e, err = casbin.NewEnforcer("testdata/rbac_model.conf", dst)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"アリス", "データ1", "読む"}, {"zoë", "café", "écrire"}, {"محمد", "数据", "写"}})
+}
+
+func TestBunAdapter_CaseSensitive(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. binary collations on the columns and in the WHERE clauses
+	tests := []struct {
+		name    string
+		dialect schema.Dialect
+		column  string
+		clause  string
+	}{
+		{
+			name:    "mysql",
+			dialect: mysqldialect.New(),
+			column:  "`v0` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin",
+			clause:  "CONVERT(v0 USING utf8mb4) COLLATE utf8mb4_bin = ?",
+		},
+		{
+			name:    "mssql",
+			dialect: mssqldialect.New(),
+			column:  `"v0" nvarchar(100) COLLATE Latin1_General_100_BIN2`,
+			clause:  "v0 COLLATE Latin1_General_100_BIN2 = ?",
+		},
+		{
+			name:    "postgres",
+			dialect: pgdialect.New(),
+			column:  `"v0" varchar(100)`,
+			clause:  "v0 = ?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &bunAdapter{db: bun.NewDB(openSQLite(t), tt.dialect), columnWidth: DefaultColumnWidth, modelType: casbinPolicyType, caseSensitive: true}
+			columnTypeMu.Lock()
+			a.setColumnTypes()
+			columnTypeMu.Unlock()
+
+			if got := a.db.NewCreateTable().Model((*CasbinPolicy)(nil)).String(); !strings.Contains(got, tt.column) {
+				t.Errorf("got %s, want it to contain %s", got, tt.column)
+			}
+			if got := a.equalClause("v0"); got != tt.clause {
+				t.Errorf("equalClause() = %s, want %s", got, tt.clause)
+			}
+		})
+	}
+
+	// 2. removes and updates on a table with a case-insensitive collation
+	sqlDB := openSQLite(t)
+	if _, err := sqlDB.ExecContext(ctx, `CREATE TABLE casbin_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ptype varchar(100) NOT NULL,
+		v0 varchar(100) COLLATE NOCASE, v1 varchar(100) COLLATE NOCASE, v2 varchar(100) COLLATE NOCASE,
+		v3 varchar(100) COLLATE NOCASE, v4 varchar(100) COLLATE NOCASE, v5 varchar(100) COLLATE NOCASE,
+		tenant_id varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+	a := newSQLiteAdapter(t, sqlDB, WithCaseSensitive(true))
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"Alice", "data1", "read"}, {"bob", "data2", "write"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if err := a.RemovePolicy("p", "p", []string{"Alice", "data1", "read"}); err != nil {
+		t.Fatalf("failed to remove policy: %v", err)
+	}
+	if err := a.UpdatePolicy("p", "p", []string{"BOB", "data2", "write"}, []string{"BOB", "data2", "read"}); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if err := a.RemoveFilteredPolicy("p", "p", 0, "ALICE"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+}
@@ -0,0 +1,145 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithIgnoreDuplicates makes AddPolicy and AddPolicies skip the rules that are already stored,
+// as AddPoliciesIgnoringDuplicates does, instead of failing on a unique index or storing the rules twice.
+func WithIgnoreDuplicates(enabled bool) Option {
+	return func(a *bunAdapter) {
+		a.ignoreDuplicates = enabled
+	}
+}
+
+// AddPoliciesIgnoringDuplicates adds the rules that are not stored yet in one transaction and returns them,
+// so that the caller can add exactly those rules to the enforcer.
+// A rule is stored when a row in effect holds the same values, compared as the removes compare them.
+// Each rule is inserted with the native conflict handling of the dialect, so that a unique index
+// on the table turns a concurrent insert of the same rule into a skip:
+// ON CONFLICT DO NOTHING on Postgres and SQLite, INSERT IGNORE on MySQL and MERGE on MSSQL.
+// Without a unique index, two concurrent calls may still both insert a rule.
+func (a *bunAdapter) AddPoliciesIgnoringDuplicates(ctx context.Context, sec string, ptype string, rules [][]string) (_ [][]string, err error) {
+	ctx, op := a.startOperation(ctx, "AddPoliciesIgnoringDuplicates", ptypeKey.String(ptype), ruleCountKey.Int(len(rules)))
+	defer func() { op.end(err) }()
+	op.setRuleValues(slog.Any("rules", rules))
+
+	policies, err := a.newPolicies(ptype, rules)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkWidth(policies...); err != nil {
+		return nil, err
+	}
+	inserted, err := a.insertAbsentPolicies(ctx, policies)
+	if err != nil {
+		return nil, err
+	}
+
+	added := make([][]string, 0, len(inserted))
+	for _, i := range inserted {
+		added = append(added, rules[i])
+	}
+	return added, nil
+}
+
+// insertAbsentPolicies inserts the policies that are not stored yet in one transaction
+// and returns the indexes of the inserted ones.
+func (a *bunAdapter) insertAbsentPolicies(ctx context.Context, policies []CasbinPolicy) ([]int, error) {
+	var inserted []int
+	err := a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		inserted = inserted[:0]
+		now := time.Now()
+		for i, policy := range policies {
+			ok, err := a.insertAbsentPolicy(ctx, tx, policy, now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				inserted = append(inserted, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// insertAbsentPolicy inserts the policy unless it is stored, and reports whether it was inserted.
+// The rows inserted earlier in tx count as stored, so a rule given twice is inserted once.
+func (a *bunAdapter) insertAbsentPolicy(ctx context.Context, tx bun.Tx, policy CasbinPolicy, now time.Time) (bool, error) {
+	var query interface {
+		Exec(ctx context.Context, dest ...interface{}) (sql.Result, error)
+	}
+	match, args := a.matchPolicy(policy, now)
+	if a.db.Dialect().Name() == dialect.MSSQL {
+		query = a.mergePolicy(tx, policy, match, args)
+	} else {
+		exists, err := tx.NewSelect().
+			Model(a.tableModel()).
+			Where(match, args...).
+			Exists(ctx)
+		if err != nil || exists {
+			return false, err
+		}
+		query = a.insertQuery(tx, []CasbinPolicy{policy}).Ignore()
+	}
+
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// matchPolicy returns the condition that matches the rows of the adapter's tenant and namespace that hold the policy's rule
+// and are in effect at now.
+func (a *bunAdapter) matchPolicy(policy CasbinPolicy, now time.Time) (string, []interface{}) {
+	values := []string{policy.PType, policy.V0, policy.V1, policy.V2, policy.V3, policy.V4, policy.V5}
+	conditions := make([]string, 0, len(ruleColumns)+4)
+	args := make([]interface{}, 0, len(ruleColumns)+4)
+	for i, column := range ruleColumns {
+		conditions = append(conditions, a.equalClause(column))
+		args = append(args, values[i])
+	}
+	scopeConditions, scopeArgs := a.scopeConditions()
+	conditions = append(conditions, scopeConditions...)
+	args = append(args, scopeArgs...)
+	if a.timeBound {
+		conditions = append(conditions, "(valid_from IS NULL OR valid_from <= ?)", "(expires_at IS NULL OR expires_at > ?)")
+		args = append(args, now, now)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// mergePolicy returns the MSSQL statement that inserts the policy unless a row matches it.
+// HOLDLOCK keeps the matched range locked until the insert, so that concurrent merges of a rule insert it once.
+func (a *bunAdapter) mergePolicy(db bun.IDB, policy CasbinPolicy, match string, args []interface{}) *bun.MergeQuery {
+	return db.NewMerge().
+		Model(a.toModel(policy)).
+		ModelTableExpr("?TableName WITH (HOLDLOCK) AS ?TableAlias").
+		Using("(SELECT 1 AS one) AS src").
+		On(match, args...).
+		WhenInsert("NOT MATCHED", func(q *bun.InsertQuery) *bun.InsertQuery {
+			if a.tenantID != "" {
+				q = q.Value(tenantColumn, "?", a.tenantID)
+			}
+			if a.namespace != "" {
+				q = q.Value(namespaceColumn, "?", a.namespace)
+			}
+			return excludeColumns(a, q)
+		})
+}
@@ -0,0 +1,138 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mssqldialect"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/schema"
+)
+
+func TestBunAdapter_AddPoliciesIgnoringDuplicates(t *testing.T) {
+	ctx := context.Background()
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"carol", "data3", "read"}, time.Time{}, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+
+	// the expired rule is not in effect, so it is added again
+	added, err := a.AddPoliciesIgnoringDuplicates(ctx, "p", "p", [][]string{
+		{"alice", "data1", "read"},
+		{"alice", "data1"},
+		{"carol", "data3", "read"},
+		{"dave", "data4", "read"},
+		{"dave", "data4", "read"},
+	})
+	if err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	want := [][]string{{"alice", "data1"}, {"carol", "data3", "read"}, {"dave", "data4", "read"}}
+	if diff := cmp.Diff(want, added); diff != "" {
+		t.Errorf("added rules mismatch (-want +got):\n%s", diff)
+	}
+	count, err := a.(*bunAdapter).db.NewSelect().Table("casbin_policies").Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("got %d rows, want 6", count)
+	}
+}
+
+func TestBunAdapter_IgnoreDuplicates(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	a := newSQLiteAdapter(t, sqlDB, WithIgnoreDuplicates(true), WithTenant("a"))
+	if _, err := sqlDB.ExecContext(ctx, "CREATE UNIQUE INDEX casbin_policies_rule ON casbin_policies (tenant_id, ptype, v0, v1, v2, v3, v4, v5)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"carol", "data3", "read"}}); err != nil {
+		t.Errorf("got %v, want the stored rule to be skipped", err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"bob", "data2", "write"}); err != nil {
+		t.Errorf("got %v, want the stored rule to be skipped", err)
+	}
+
+	// another tenant stores the same rules
+	other := newSQLiteAdapter(t, sqlDB, WithTenant("b"))
+	added, err := other.AddPoliciesIgnoringDuplicates(ctx, "p", "p", [][]string{{"alice", "data1", "read"}})
+	if err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if diff := cmp.Diff([][]string{{"alice", "data1", "read"}}, added); diff != "" {
+		t.Errorf("added rules mismatch (-want +got):\n%s", diff)
+	}
+
+	for tenant, want := range map[string]int{"a": 3, "b": 1} {
+		count, err := a.(*bunAdapter).db.NewSelect().Table("casbin_policies").Where("tenant_id = ?", tenant).Count(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("got %d rows of tenant %s, want %d", count, tenant, want)
+		}
+	}
+}
+
+func TestBunAdapter_insertAbsentPolicyQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect schema.Dialect
+		want    string
+	}{
+		{
+			name:    "sqlite",
+			dialect: sqlitedialect.New(),
+			want:    "ON CONFLICT DO NOTHING",
+		},
+		{
+			name:    "postgres",
+			dialect: pgdialect.New(),
+			want:    "ON CONFLICT DO NOTHING",
+		},
+		{
+			name:    "mysql",
+			dialect: mysqldialect.New(),
+			want:    "INSERT IGNORE INTO",
+		},
+	}
+	policy := newCasbinPolicy("p", []string{"alice", "data1", "read"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &bunAdapter{db: bun.NewDB(openSQLite(t), tt.dialect), modelType: casbinPolicyType}
+			if got := a.insertQuery(a.db, []CasbinPolicy{policy}).Ignore().String(); !strings.Contains(got, tt.want) {
+				t.Errorf("got %s, want it to contain %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("mssql", func(t *testing.T) {
+		a := &bunAdapter{db: bun.NewDB(openSQLite(t), mssqldialect.New()), modelType: casbinPolicyType, tenantID: "a"}
+		match, args := a.matchPolicy(policy, time.Now())
+		got := a.mergePolicy(a.db, policy, match, args).String()
+		for _, want := range []string{
+			`MERGE "casbin_policies" WITH (HOLDLOCK) AS "cp" USING (SELECT 1 AS one) AS src`,
+			"ON ptype = N'p' AND v0 = N'alice' AND v1 = N'data1' AND v2 = N'read' AND v3 = N'' AND v4 = N'' AND v5 = N'' AND tenant_id = N'a'",
+			`WHEN NOT MATCHED THEN INSERT ("id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "tenant_id") VALUES (DEFAULT, N'p', N'alice', N'data1', N'read', N'', N'', N'', N'a');`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("got %s, want it to contain %s", got, want)
+			}
+		}
+	})
+}
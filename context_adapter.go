@@ -19,14 +19,24 @@ type ctxBunAdapter struct {
 	persist.Adapter
 }
 
-func NewCtxAdapter(driverName string, dataSourceName string) (persist.ContextAdapter, error) {
-	adapter, err := NewAdapter(driverName, dataSourceName)
+func NewCtxAdapter(driverName string, dataSourceName string, opts ...Option) (persist.ContextAdapter, error) {
+	adapter, err := NewAdapter(driverName, dataSourceName, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return &ctxBunAdapter{Adapter: adapter}, nil
 }
 
+// adapterFor returns the adapter scoped to the tenant carried by ctx, if any.
+// The ctxBunAdapter always wraps a bunAdapter, whose operations take the caller's context.
+func (a *ctxBunAdapter) adapterFor(ctx context.Context) persist.ContextAdapter {
+	b := a.Adapter.(*bunAdapter)
+	if tenantID, ok := TenantFromContext(ctx); ok {
+		return b.withTenant(tenantID)
+	}
+	return b
+}
+
 // executeWithContext is a helper function to execute a function with context and return the result or error.
 func executeWithContext(ctx context.Context, fn func() error) error {
 	done := make(chan error)
@@ -45,14 +55,14 @@ func executeWithContext(ctx context.Context, fn func() error) error {
 // LoadPolicyCtx loads all policy rules from the storage with context.
 func (a *ctxBunAdapter) LoadPolicyCtx(ctx context.Context, model model.Model) error {
 	return executeWithContext(ctx, func() error {
-		return a.LoadPolicy(model)
+		return a.adapterFor(ctx).LoadPolicyCtx(ctx, model)
 	})
 }
 
 // SavePolicyCtx saves all policy rules to the storage with context.
 func (a *ctxBunAdapter) SavePolicyCtx(ctx context.Context, model model.Model) error {
 	return executeWithContext(ctx, func() error {
-		return a.SavePolicy(model)
+		return a.adapterFor(ctx).SavePolicyCtx(ctx, model)
 	})
 }
 
@@ -60,7 +70,7 @@ func (a *ctxBunAdapter) SavePolicyCtx(ctx context.Context, model model.Model) er
 // This is part of the Auto-Save feature.
 func (a *ctxBunAdapter) AddPolicyCtx(ctx context.Context, sec string, ptype string, rule []string) error {
 	return executeWithContext(ctx, func() error {
-		return a.AddPolicy(sec, ptype, rule)
+		return a.adapterFor(ctx).AddPolicyCtx(ctx, sec, ptype, rule)
 	})
 }
 
@@ -68,7 +78,7 @@ func (a *ctxBunAdapter) AddPolicyCtx(ctx context.Context, sec string, ptype stri
 // This is part of the Auto-Save feature.
 func (a *ctxBunAdapter) RemovePolicyCtx(ctx context.Context, sec string, ptype string, rule []string) error {
 	return executeWithContext(ctx, func() error {
-		return a.RemovePolicy(sec, ptype, rule)
+		return a.adapterFor(ctx).RemovePolicyCtx(ctx, sec, ptype, rule)
 	})
 }
 
@@ -76,6 +86,6 @@ func (a *ctxBunAdapter) RemovePolicyCtx(ctx context.Context, sec string, ptype s
 // This is part of the Auto-Save feature.
 func (a *ctxBunAdapter) RemoveFilteredPolicyCtx(ctx context.Context, sec string, ptype string, fieldIndex int, fieldValues ...string) error {
 	return executeWithContext(ctx, func() error {
-		return a.RemoveFilteredPolicy(sec, ptype, fieldIndex, fieldValues...)
+		return a.adapterFor(ctx).RemoveFilteredPolicyCtx(ctx, sec, ptype, fieldIndex, fieldValues...)
 	})
 }
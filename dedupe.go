@@ -0,0 +1,63 @@
+package casbinbunadapter
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// Deduplicate deletes the rows that repeat the rule of another row in one transaction,
+// keeping the row with the lowest id of each rule, and returns the number of rows deleted.
+// Rows of different tenants or namespaces, or with a different expiry, are not duplicates of each other.
+// On a case-sensitive adapter, rules that differ only in case are not duplicates either.
+func (a *bunAdapter) Deduplicate(ctx context.Context) (_ int64, err error) {
+	ctx, op := a.startOperation(ctx, "Deduplicate")
+	defer func() { op.end(err) }()
+
+	table := a.modelTable()
+	live, err := a.liveColumns(ctx, a.db, table.Name)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	groups := make([]string, 0, len(ruleColumns)+2+len(expiryColumns))
+	for _, column := range ruleColumns {
+		groups = append(groups, a.collated(column))
+	}
+	if _, ok := live[tenantColumn]; ok {
+		groups = append(groups, tenantColumn)
+	}
+	if _, ok := live[namespaceColumn]; ok {
+		groups = append(groups, namespaceColumn)
+	}
+	if a.timeBound {
+		groups = append(groups, expiryColumns...)
+	}
+
+	var deleted int64
+	err = a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		first := tx.NewSelect().
+			Model(a.tableModel()).
+			ColumnExpr("MIN(id) AS id")
+		for _, group := range groups {
+			first = first.GroupExpr(group)
+		}
+		// the ids are selected through a derived table, since MySQL cannot delete
+		// from a table that a subquery of the same statement reads
+		keep := tx.NewSelect().
+			TableExpr("(?) AS keep", scopeQuery(a, first)).
+			Column("id")
+		query := tx.NewDelete().
+			Model(a.tableModel()).
+			Where("id NOT IN (?)", keep)
+		res, err := scopeQuery(a, query).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		deleted, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
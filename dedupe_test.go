@@ -0,0 +1,81 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBunAdapter_Deduplicate(t *testing.T) {
+	ctx := context.Background()
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	rules := [][]string{
+		{"alice", "data1", "read"},
+		{"bob", "data2", "write"},
+		{"alice", "data1", "read"},
+		{"alice", "data1", "read"},
+		{"Alice", "data1", "read"},
+	}
+	if err := a.AddPolicies("p", "p", rules); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"bob", "data2", "write"}, time.Time{}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+	if err := a.AddPolicies("g", "g", [][]string{{"alice", "admin"}, {"alice", "admin"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+
+	deleted, err := a.Deduplicate(ctx)
+	if err != nil {
+		t.Fatalf("failed to deduplicate: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("got %d rows deleted, want 3", deleted)
+	}
+	var ids []int64
+	if err := a.(*bunAdapter).db.NewSelect().Table("casbin_policies").Column("id").Order("id").Scan(ctx, &ids); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]int64{1, 2, 5, 6, 7}, ids); diff != "" {
+		t.Errorf("ids mismatch (-want +got):\n%s", diff)
+	}
+
+	if deleted, err := a.Deduplicate(ctx); err != nil || deleted != 0 {
+		t.Errorf("got %d, %v from the second call, want nothing deleted", deleted, err)
+	}
+}
+
+func TestBunAdapter_DeduplicateTenant(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	tenantA := newSQLiteAdapter(t, sqlDB, WithTenant("a"))
+	tenantB := newSQLiteAdapter(t, sqlDB, WithTenant("b"))
+	rules := [][]string{{"alice", "data1", "read"}, {"alice", "data1", "read"}}
+	for _, a := range []Adapter{tenantA, tenantB} {
+		if err := a.AddPolicies("p", "p", rules); err != nil {
+			t.Fatalf("failed to add policies: %v", err)
+		}
+	}
+
+	deleted, err := tenantA.Deduplicate(ctx)
+	if err != nil {
+		t.Fatalf("failed to deduplicate: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("got %d rows deleted, want 1", deleted)
+	}
+	for tenant, want := range map[string]int{"a": 1, "b": 2} {
+		count, err := tenantA.(*bunAdapter).db.NewSelect().Table("casbin_policies").Where("tenant_id = ?", tenant).Count(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("got %d rows of tenant %s, want %d", count, tenant, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package casbinbunadapter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	// ErrUnsupportedDriver is returned by the constructors for a driver name they cannot open.
+	ErrUnsupportedDriver = errors.New("unsupported driver")
+	// ErrTableMissing is returned when the policy table does not exist.
+	ErrTableMissing = errors.New("policy table does not exist")
+	// ErrDuplicatePolicy is returned when a rule violates a unique constraint of the policy table.
+	ErrDuplicatePolicy = errors.New("policy rule already exists")
+	// ErrPolicyNotFound is returned by RemovePolicy, RemovePolicies, UpdatePolicy and UpdatePolicies
+	// for a rule that matches no stored row, if the adapter was created with WithPolicyNotFound.
+	ErrPolicyNotFound = errors.New("policy rule not found")
+	// ErrRuleTooLong is returned when a value of a rule does not fit in its column.
+	ErrRuleTooLong = errors.New("policy rule value is too long")
+	// ErrTooManyFields is returned for a rule with more values than the v0 to v5 columns.
+	ErrTooManyFields = errors.New("policy rule has too many fields")
+	// ErrFilteredSaveForbidden is returned by SavePolicy after LoadFilteredPolicy,
+	// since saving a filtered model would delete every rule outside the filter.
+	ErrFilteredSaveForbidden = errors.New("cannot save a filtered policy")
+	// ErrMigrationMismatch is returned by MigrateFrom when the verification finds
+	// the policy table and the source table to differ.
+	ErrMigrationMismatch = errors.New("migrated rules do not match the source table")
+	// ErrExpiryUnsupported is returned by AddPolicyWithExpiry and Import when the policy table
+	// has no valid_from and expires_at columns. UpgradeSchema adds them.
+	ErrExpiryUnsupported = errors.New("policy table has no valid_from and expires_at columns")
+	// ErrConcurrentModification is returned by SavePolicy when the stored rules were changed
+	// since LoadPolicy read them. Load the policy again and reapply the changes.
+	ErrConcurrentModification = errors.New("policy was modified since it was loaded")
+	// ErrLockTimeout is returned when the lock of the policy table was not acquired within the lock timeout.
+	ErrLockTimeout = errors.New("timed out waiting for the policy table lock")
+	// ErrTransactionFailed is matched by every error that made a transaction of the adapter roll back.
+	ErrTransactionFailed = errors.New("transaction failed")
+)
+
+// DriverError is a database error that was classified as one of the sentinel errors.
+// errors.Is matches both the sentinel and the original driver error.
+type DriverError struct {
+	// Kind is the sentinel error, like ErrDuplicatePolicy.
+	Kind error
+	// Code is the error number or SQLSTATE reported by the driver, empty for SQLite.
+	Code string
+	// Err is the original driver error.
+	Err error
+}
+
+func (e *DriverError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *DriverError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// RuleTooLongError is returned, before anything is written, for a rule value longer than its column.
+// errors.Is matches it with ErrRuleTooLong.
+type RuleTooLongError struct {
+	// Field is the column of the value, ptype, v0 to v5, tenant_id or namespace.
+	Field string
+	// Length is the number of characters in the value, counted in UTF-16 code units on MSSQL.
+	Length int
+	// Width is the number of characters that the column holds.
+	Width int
+}
+
+func (e *RuleTooLongError) Error() string {
+	return fmt.Sprintf("%s: %s has %d characters, the column holds %d", ErrRuleTooLong, e.Field, e.Length, e.Width)
+}
+
+func (e *RuleTooLongError) Unwrap() error {
+	return ErrRuleTooLong
+}
+
+// TxError is returned when a transaction of the adapter rolled back.
+// errors.Is matches both ErrTransactionFailed and the error that caused the rollback.
+type TxError struct {
+	Err error
+}
+
+func (e *TxError) Error() string {
+	return ErrTransactionFailed.Error() + ": " + e.Err.Error()
+}
+
+func (e *TxError) Unwrap() []error {
+	return []error{ErrTransactionFailed, e.Err}
+}
+
+// classifyError maps the errors of the MySQL, Postgres, SQLite and MSSQL drivers to a DriverError.
+// Errors that are already classified, or that match none of the sentinels, are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var driverErr *DriverError
+	var txErr *TxError
+	if errors.As(err, &driverErr) || errors.As(err, &txErr) {
+		return err
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return classifyCode(err, strconv.Itoa(int(mysqlErr.Number)), map[string]error{
+			"1062": ErrDuplicatePolicy,
+			"1146": ErrTableMissing,
+			"1406": ErrRuleTooLong,
+		})
+	}
+	if state, ok := postgresSQLState(err); ok {
+		return classifyCode(err, state, map[string]error{
+			"23505": ErrDuplicatePolicy,
+			"42P01": ErrTableMissing,
+			"22001": ErrRuleTooLong,
+		})
+	}
+	if number, ok := mssqlErrorNumber(err); ok {
+		return classifyCode(err, strconv.Itoa(int(number)), map[string]error{
+			"2601": ErrDuplicatePolicy,
+			"2627": ErrDuplicatePolicy,
+			"208":  ErrTableMissing,
+			"2628": ErrRuleTooLong,
+			"8152": ErrRuleTooLong,
+		})
+	}
+
+	// The SQLite drivers are not imported by the adapter, so their errors are matched by message.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed"):
+		return &DriverError{Kind: ErrDuplicatePolicy, Err: err}
+	case strings.Contains(msg, "no such table"):
+		return &DriverError{Kind: ErrTableMissing, Err: err}
+	}
+	return err
+}
+
+func classifyCode(err error, code string, kinds map[string]error) error {
+	kind, ok := kinds[code]
+	if !ok {
+		return err
+	}
+	return &DriverError{Kind: kind, Code: code, Err: err}
+}
@@ -0,0 +1,122 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "mysql duplicate entry", err: &mysql.MySQLError{Number: 1062}, want: ErrDuplicatePolicy},
+		{name: "mysql missing table", err: &mysql.MySQLError{Number: 1146}, want: ErrTableMissing},
+		{name: "mysql data too long", err: &mysql.MySQLError{Number: 1406}, want: ErrRuleTooLong},
+		{name: "postgres unique violation", err: sqlStateError("23505"), want: ErrDuplicatePolicy},
+		{name: "postgres undefined table", err: fmt.Errorf("wrapped: %w", sqlStateError("42P01")), want: ErrTableMissing},
+		{name: "postgres string too long", err: sqlStateError("22001"), want: ErrRuleTooLong},
+		{name: "mssql unique key", err: mssql.Error{Number: 2627}, want: ErrDuplicatePolicy},
+		{name: "mssql unique index", err: mssql.Error{Number: 2601}, want: ErrDuplicatePolicy},
+		{name: "mssql invalid object", err: mssql.Error{Number: 208}, want: ErrTableMissing},
+		{name: "mssql truncated", err: mssql.Error{Number: 2628}, want: ErrRuleTooLong},
+		{name: "sqlite unique constraint", err: errors.New("UNIQUE constraint failed: casbin_policies.v0"), want: ErrDuplicatePolicy},
+		{name: "sqlite missing table", err: errors.New("no such table: casbin_policies"), want: ErrTableMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("classifyError() = %v, want %v", got, tt.want)
+			}
+			var driverErr *DriverError
+			if !errors.As(got, &driverErr) {
+				t.Fatalf("classifyError() = %T, want *DriverError", got)
+			}
+			if driverErr.Err.Error() != tt.err.Error() {
+				t.Errorf("classifyError() wraps %v, want %v", driverErr.Err, tt.err)
+			}
+		})
+	}
+
+	// errors that match no sentinel are returned unchanged
+	for _, err := range []error{&mysql.MySQLError{Number: 1213}, sqlStateError("40001"), sql.ErrNoRows, errors.New("boom")} {
+		if got := classifyError(err); got != err {
+			t.Errorf("classifyError(%v) = %v, want it unchanged", err, got)
+		}
+	}
+}
+
+func TestBunAdapter_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. unsupported driver
+	if _, err := NewAdapter("oracle", ""); !errors.Is(err, ErrUnsupportedDriver) {
+		t.Errorf("got %v, want ErrUnsupportedDriver", err)
+	}
+
+	// 2. too many fields
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if err := a.AddPolicy("p", "p", []string{"1", "2", "3", "4", "5", "6", "7"}); !errors.Is(err, ErrTooManyFields) {
+		t.Errorf("got %v, want ErrTooManyFields", err)
+	}
+
+	// 3. filtered save
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if err := a.LoadFilteredPolicy(e.GetModel(), Filter{PType: []string{"p"}}); err != nil {
+		t.Fatalf("failed to load filtered policy: %v", err)
+	}
+	if err := a.SavePolicy(e.GetModel()); !errors.Is(err, ErrFilteredSaveForbidden) {
+		t.Errorf("got %v, want ErrFilteredSaveForbidden", err)
+	}
+	if err := a.LoadPolicy(e.GetModel()); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Errorf("failed to save policy: %v", err)
+	}
+
+	// 4. duplicate policy, once the table has a unique index
+	b := a.(*bunAdapter)
+	if _, err := b.db.ExecContext(ctx, "CREATE UNIQUE INDEX idx_casbin_policies_rule ON casbin_policies (ptype, v0, v1, v2)"); err != nil {
+		t.Fatal(err)
+	}
+	err = a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	var driverErr *DriverError
+	if !errors.Is(err, ErrDuplicatePolicy) || !errors.As(err, &driverErr) {
+		t.Errorf("got %v, want a *DriverError for ErrDuplicatePolicy", err)
+	}
+
+	// 5. a failed transaction
+	err = a.UpdatePolicies("p", "p", [][]string{{"alice", "data1", "read"}}, [][]string{{"alice", "data1", "read"}})
+	if err != nil {
+		t.Fatalf("failed to update policies: %v", err)
+	}
+	if _, err := b.db.ExecContext(ctx, "DROP TABLE casbin_policies"); err != nil {
+		t.Fatal(err)
+	}
+	err = a.RemovePolicies("p", "p", [][]string{{"alice", "data1", "read"}})
+	var txErr *TxError
+	if !errors.Is(err, ErrTransactionFailed) || !errors.Is(err, ErrTableMissing) || !errors.As(err, &txErr) {
+		t.Errorf("got %v, want a *TxError for ErrTableMissing", err)
+	}
+
+	// 6. table missing
+	if err := a.LoadPolicy(e.GetModel()); !errors.Is(err, ErrTableMissing) {
+		t.Errorf("got %v, want ErrTableMissing", err)
+	}
+}
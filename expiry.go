@@ -0,0 +1,199 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// expiryColumns are the optional columns that bound the time during which a policy rule is in effect.
+var expiryColumns = []string{"valid_from", "expires_at"}
+
+var nullTimeType = reflect.TypeOf(bun.NullTime{})
+
+// ExpiredPolicy is a policy rule deleted by Purge.
+type ExpiredPolicy struct {
+	Sec       string
+	PType     string
+	Rule      []string
+	ExpiresAt time.Time
+}
+
+// AddPolicyWithExpiry adds a policy rule that is only in effect from validFrom until expiresAt.
+// A zero validFrom or expiresAt leaves that end open.
+// LoadPolicy and LoadFilteredPolicy leave the rule out before validFrom and from expiresAt on,
+// and SavePolicy keeps its expiry.
+func (a *bunAdapter) AddPolicyWithExpiry(ctx context.Context, sec string, ptype string, rule []string, validFrom, expiresAt time.Time) (err error) {
+	ctx, op := a.startOperation(ctx, "AddPolicyWithExpiry", ptypeKey.String(ptype), ruleCountKey.Int(1))
+	defer func() { op.end(err) }()
+	op.addLogAttrs(slog.Time("valid_from", validFrom), slog.Time("expires_at", expiresAt))
+	op.setRuleValues(slog.Any("rule", rule))
+
+	if !a.timeBound {
+		return ErrExpiryUnsupported
+	}
+	if !validFrom.IsZero() && !expiresAt.IsZero() && !expiresAt.After(validFrom) {
+		return errors.New("policy rule must expire after it becomes valid")
+	}
+
+	policy, err := a.newPolicy(ptype, rule)
+	if err != nil {
+		return err
+	}
+	policy.ValidFrom = bun.NullTime{Time: validFrom}
+	policy.ExpiresAt = bun.NullTime{Time: expiresAt}
+	if err := a.checkWidth(policy); err != nil {
+		return err
+	}
+	return a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		return a.insertPolicies(ctx, tx, []CasbinPolicy{policy})
+	})
+}
+
+// Purge deletes the expired policy rules in one transaction and returns them,
+// so that they can also be dropped from an enforcer that loaded them before they expired.
+// It does nothing when the policy table has no expires_at column.
+func (a *bunAdapter) Purge(ctx context.Context) (_ []ExpiredPolicy, err error) {
+	ctx, op := a.startOperation(ctx, "Purge")
+	defer func() { op.end(err) }()
+
+	if !a.timeBound {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var expired []ExpiredPolicy
+	err = a.writeInTx(ctx, func(ctx context.Context, tx bun.Tx) error {
+		selectQuery := tx.NewSelect().
+			Model(a.tableModel()).
+			Where("expires_at <= ?", now).
+			Order("id")
+		policies, err := a.collectPolicies(ctx, scopeQuery(a, excludeColumns(a, selectQuery)))
+		if err != nil {
+			return err
+		}
+
+		deleteQuery := tx.NewDelete().
+			Model(a.tableModel()).
+			Where("expires_at <= ?", now)
+		if _, err := scopeQuery(a, deleteQuery).Exec(ctx); err != nil {
+			return err
+		}
+
+		expired = make([]ExpiredPolicy, 0, len(policies))
+		for _, policy := range policies {
+			expired = append(expired, ExpiredPolicy{
+				Sec:       policy.PType[:1],
+				PType:     policy.PType,
+				Rule:      policy.ruleValues(),
+				ExpiresAt: policy.ExpiresAt.Time,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	op.setRuleCount(len(expired))
+	return expired, nil
+}
+
+// activeQuery restricts the query to the rules in effect at now.
+func activeQuery[Q whereQuery[Q]](a *bunAdapter, query Q, now time.Time) Q {
+	if !a.timeBound {
+		return query
+	}
+	return query.
+		Where("valid_from IS NULL OR valid_from <= ?", now).
+		Where("expires_at IS NULL OR expires_at > ?", now)
+}
+
+// keepTimeBounds gives the policies that SavePolicy writes the expiry of the stored rules they match.
+// The stored rules that are not in effect yet are kept as well, since LoadPolicy left them out of the model.
+func (a *bunAdapter) keepTimeBounds(ctx context.Context, db bun.IDB, policies []CasbinPolicy) ([]CasbinPolicy, error) {
+	if !a.timeBound {
+		return policies, nil
+	}
+
+	now := time.Now()
+	query := db.NewSelect().
+		Model(a.tableModel()).
+		Where("valid_from IS NOT NULL OR expires_at IS NOT NULL").
+		Order("id")
+	stored, err := a.collectPolicies(ctx, scopeQuery(a, excludeColumns(a, query)))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := make(map[CasbinPolicy]CasbinPolicy, len(stored))
+	for _, policy := range stored {
+		bounds[policy.ruleKey()] = policy
+	}
+	for i := range policies {
+		if policy, ok := bounds[policies[i].ruleKey()]; ok {
+			policies[i].ValidFrom, policies[i].ExpiresAt = policy.ValidFrom, policy.ExpiresAt
+			delete(bounds, policies[i].ruleKey())
+		}
+	}
+	for _, policy := range stored {
+		if _, ok := bounds[policy.ruleKey()]; ok && policy.ValidFrom.After(now) {
+			delete(bounds, policy.ruleKey())
+			policy.ID = 0
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+// ruleKey returns the policy without its id and expiry, to compare rules.
+func (c CasbinPolicy) ruleKey() CasbinPolicy {
+	c.ID = 0
+	c.ValidFrom, c.ExpiresAt = bun.NullTime{}, bun.NullTime{}
+	return c
+}
+
+// detectTimeBound reports whether both the policy model and the live table have the expiry columns.
+func (a *bunAdapter) detectTimeBound(ctx context.Context, db bun.IDB) (bool, error) {
+	table := a.modelTable()
+	for _, column := range expiryColumns {
+		field, ok := table.FieldMap[column]
+		if !ok || field.StructField.Type != nullTimeType {
+			return false, nil
+		}
+	}
+
+	live, err := a.liveColumns(ctx, db, table.Name)
+	if err != nil {
+		return false, err
+	}
+	for _, column := range expiryColumns {
+		if _, ok := live[column]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// setTimeBounds sets the expiry columns of a policy model other than CasbinPolicy.
+func (a *bunAdapter) setTimeBounds(m reflect.Value, policy CasbinPolicy) {
+	if !a.timeBound {
+		return
+	}
+	table := a.modelTable()
+	table.FieldMap["valid_from"].Value(m).Set(reflect.ValueOf(policy.ValidFrom))
+	table.FieldMap["expires_at"].Value(m).Set(reflect.ValueOf(policy.ExpiresAt))
+}
+
+// getTimeBounds reads the expiry columns of a policy model other than CasbinPolicy.
+func (a *bunAdapter) getTimeBounds(m reflect.Value, policy *CasbinPolicy) {
+	if !a.timeBound {
+		return
+	}
+	table := a.modelTable()
+	policy.ValidFrom = table.FieldMap["valid_from"].Value(m).Interface().(bun.NullTime)
+	policy.ExpiresAt = table.FieldMap["expires_at"].Value(m).Interface().(bun.NullTime)
+}
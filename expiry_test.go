@@ -0,0 +1,167 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBunAdapter_AddPolicyWithExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "page size", opts: []Option{WithLoadPageSize(1)}},
+		{name: "fast load", opts: []Option{WithFastLoad(FastLoadDistinct)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newSQLiteAdapter(t, openSQLite(t), tt.opts...)
+			if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+				t.Fatalf("failed to add policy: %v", err)
+			}
+			for _, p := range []struct {
+				rule                 []string
+				validFrom, expiresAt time.Time
+			}{
+				{rule: []string{"bob", "data2", "write"}, expiresAt: now.Add(-time.Hour)},
+				{rule: []string{"carol", "data3", "read"}, expiresAt: now.Add(4 * time.Hour)},
+				{rule: []string{"dave", "data4", "read"}, validFrom: now.Add(time.Hour)},
+			} {
+				if err := a.AddPolicyWithExpiry(ctx, "p", "p", p.rule, p.validFrom, p.expiresAt); err != nil {
+					t.Fatalf("failed to add policy with expiry: %v", err)
+				}
+			}
+
+			e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+			if err != nil {
+				t.Fatalf("failed to create enforcer: %v", err)
+			}
+			testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"carol", "data3", "read"}})
+
+			if err := e.LoadFilteredPolicy(Filter{PType: []string{"p"}}); err != nil {
+				t.Fatalf("failed to load filtered policy: %v", err)
+			}
+			testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"carol", "data3", "read"}})
+		})
+	}
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"alice", "data1", "read"}, now, now.Add(-time.Hour)); err == nil {
+		t.Error("got no error for a rule that expires before it becomes valid")
+	}
+}
+
+func TestBunAdapter_SavePolicyKeepsExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"alice", "data1", "read"}, time.Time{}, now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"bob", "data2", "read"}, now.Add(time.Hour), time.Time{}); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if _, err := e.AddPolicy("carol", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+
+	var rows []CasbinPolicy
+	if err := a.(*bunAdapter).db.NewSelect().Model(&rows).ExcludeColumn(tenantColumn).Order("v0").Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	if rows[0].V0 != "alice" || !rows[0].ExpiresAt.Time.Equal(now.Add(time.Hour)) || !rows[0].ValidFrom.IsZero() {
+		t.Errorf("got %+v, want alice's rule to keep its expiry", rows[0])
+	}
+	if rows[1].V0 != "bob" || !rows[1].ValidFrom.Time.Equal(now.Add(time.Hour)) {
+		t.Errorf("got %+v, want bob's rule to be kept until it becomes valid", rows[1])
+	}
+	if rows[2].V0 != "carol" || !rows[2].ValidFrom.IsZero() || !rows[2].ExpiresAt.IsZero() {
+		t.Errorf("got %+v, want carol's rule without expiry", rows[2])
+	}
+}
+
+func TestBunAdapter_Purge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"bob", "data2", "write"}, time.Time{}, now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if err := a.AddPolicyWithExpiry(ctx, "g", "g", []string{"alice", "admin"}, time.Time{}, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+
+	expired, err := a.Purge(ctx)
+	if err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+	want := []ExpiredPolicy{{Sec: "g", PType: "g", Rule: []string{"alice", "admin"}, ExpiresAt: now.Add(-time.Minute)}}
+	if diff := cmp.Diff(want, expired, cmp.Comparer(func(x, y time.Time) bool { return x.Equal(y) })); diff != "" {
+		t.Errorf("Purge() mismatch (-want +got):\n%s", diff)
+	}
+	if expired, err := a.Purge(ctx); err != nil || len(expired) != 0 {
+		t.Errorf("got %v, %v from the second purge, want nothing", expired, err)
+	}
+
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+}
+
+func TestBunAdapter_ExpiryUnsupported(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	if _, err := sqlDB.ExecContext(ctx, `CREATE TABLE casbin_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ptype varchar(100) NOT NULL,
+		v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100), v4 varchar(100), v5 varchar(100),
+		tenant_id varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+	a := newSQLiteAdapter(t, sqlDB)
+	initPolicy(t, a)
+	expiresAt := time.Now().Add(time.Hour)
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"alice", "data1", "read"}, time.Time{}, expiresAt); !errors.Is(err, ErrExpiryUnsupported) {
+		t.Errorf("got %v, want ErrExpiryUnsupported", err)
+	}
+	if expired, err := a.Purge(ctx); err != nil || len(expired) != 0 {
+		t.Errorf("got %v, %v, want nothing to purge", expired, err)
+	}
+
+	if _, err := a.UpgradeSchema(ctx); err != nil {
+		t.Fatalf("failed to upgrade schema: %v", err)
+	}
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"erin", "data5", "read"}, time.Time{}, expiresAt); err != nil {
+		t.Errorf("failed to add policy with expiry after the upgrade: %v", err)
+	}
+}
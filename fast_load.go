@@ -0,0 +1,68 @@
+package casbinbunadapter
+
+import (
+	"github.com/casbin/casbin/v2/model"
+	"github.com/uptrace/bun"
+)
+
+// FastLoad selects how LoadPolicy avoids the per-row duplicate check.
+type FastLoad int
+
+const (
+	// FastLoadOff checks every row with HasPolicyEx before adding it to the model.
+	FastLoadOff FastLoad = iota
+	// FastLoadDistinct lets the database drop duplicate rows with SELECT DISTINCT.
+	FastLoadDistinct
+	// FastLoadUnique trusts a unique index on ptype and v0 to v5 to keep the rows unique.
+	FastLoadUnique
+)
+
+// loadBatchSize is the number of rules added to the model by one AddPolicies call.
+const loadBatchSize = 1000
+
+// WithFastLoad makes LoadPolicy skip the per-row HasPolicyEx check
+// and add the rules in batches through the model's AddPolicies.
+// The rule sizes are then no longer validated against the model while loading.
+func WithFastLoad(mode FastLoad) Option {
+	return func(a *bunAdapter) {
+		a.fastLoad = mode
+	}
+}
+
+// selectDistinctPolicies returns a query that selects the distinct rules of db visible to the adapter.
+func (a *bunAdapter) selectDistinctPolicies(db bun.IDB) *bun.SelectQuery {
+	query := db.NewSelect().
+		Model(a.tableModel()).
+		Distinct().
+		Column("ptype", "v0", "v1", "v2", "v3", "v4", "v5")
+	return scopeQuery(a, query)
+}
+
+// policyBatch collects consecutive rules of the same ptype and adds them to the model at once.
+type policyBatch struct {
+	model model.Model
+	ptype string
+	rules [][]string
+}
+
+func (b *policyBatch) add(policy CasbinPolicy) error {
+	if policy.PType != b.ptype || len(b.rules) == loadBatchSize {
+		if err := b.flush(); err != nil {
+			return err
+		}
+		b.ptype = policy.PType
+	}
+	b.rules = append(b.rules, policy.filterValues())
+	return nil
+}
+
+func (b *policyBatch) flush() error {
+	if len(b.rules) == 0 {
+		return nil
+	}
+	if err := b.model.AddPolicies(b.ptype[:1], b.ptype, b.rules); err != nil {
+		return err
+	}
+	b.rules = b.rules[:0]
+	return nil
+}
@@ -0,0 +1,80 @@
+package casbinbunadapter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestBunAdapter_FastLoad(t *testing.T) {
+	for _, mode := range []FastLoad{FastLoadDistinct, FastLoadUnique} {
+		a := newSQLiteAdapter(t, openSQLite(t), WithFastLoad(mode), WithLoadPageSize(2))
+		initPolicy(t, a)
+
+		e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+		if err != nil {
+			t.Fatalf("failed to create enforcer: %v", err)
+		}
+		testGetPolicy(
+			t,
+			e,
+			[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		)
+		if ok, _ := e.Enforce("alice", "data2", "write"); !ok {
+			t.Errorf("mode %d: alice should inherit the permissions of data2_admin", mode)
+		}
+	}
+}
+
+func TestBunAdapter_FastLoadDistinct(t *testing.T) {
+	a := newSQLiteAdapter(t, openSQLite(t), WithFastLoad(FastLoadDistinct))
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"alice", "data1", "read"}, {"bob", "data2", "write"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+}
+
+func BenchmarkBunAdapter_LoadPolicy(b *testing.B) {
+	rules := make([][]string, 0, 10000)
+	for i := 0; i < cap(rules); i++ {
+		rules = append(rules, []string{fmt.Sprintf("user%d", i), fmt.Sprintf("data%d", i%100), "read"})
+	}
+
+	for _, bm := range []struct {
+		name string
+		mode FastLoad
+	}{
+		{name: "HasPolicyEx", mode: FastLoadOff},
+		{name: "Distinct", mode: FastLoadDistinct},
+		{name: "Unique", mode: FastLoadUnique},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			a, err := NewAdapterWithSqlDB(openSQLite(b), "sqlite3", WithFastLoad(bm.mode))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := a.AddPolicies("p", "p", rules); err != nil {
+				b.Fatal(err)
+			}
+			e, err := casbin.NewEnforcer("testdata/rbac_model.conf")
+			if err != nil {
+				b.Fatal(err)
+			}
+			m := e.GetModel()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.ClearPolicy()
+				if err := a.LoadPolicy(m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/uptrace/bun"
+)
+
+// Filter selects the policy rules loaded by LoadFilteredPolicy.
+// A rule is loaded when every non-empty field of the filter contains the rule's value for that field.
+type Filter struct {
+	PType []string
+	V0    []string
+	V1    []string
+	V2    []string
+	V3    []string
+	V4    []string
+	V5    []string
+}
+
+// LoadFilteredPolicy loads only the policy rules that match the filter.
+// The filter must be a Filter or a *Filter.
+func (a *bunAdapter) LoadFilteredPolicy(model model.Model, filter interface{}) error {
+	return a.LoadFilteredPolicyCtx(context.Background(), model, filter)
+}
+
+// LoadFilteredPolicyCtx loads only the policy rules that match the filter with context.
+func (a *bunAdapter) LoadFilteredPolicyCtx(ctx context.Context, model model.Model, filter interface{}) (err error) {
+	ctx, op := a.startOperation(ctx, "LoadFilteredPolicy")
+	defer func() { op.end(err) }()
+
+	var f Filter
+	switch v := filter.(type) {
+	case Filter:
+		f = v
+	case *Filter:
+		f = *v
+	default:
+		return errors.New("invalid filter type")
+	}
+
+	query := activeQuery(a, a.selectPolicies(a.readDB()), time.Now())
+	query = applyFilter(query, f)
+	count := 0
+	if err := a.forEachPolicy(ctx, query, func(policy CasbinPolicy) error {
+		count++
+		return loadPolicyRecord(policy, model)
+	}); err != nil {
+		return classifyError(err)
+	}
+	op.setRuleCount(count)
+
+	a.isFiltered = true
+	return nil
+}
+
+// IsFiltered returns true if the loaded policy has been filtered.
+func (a *bunAdapter) IsFiltered() bool {
+	return a.isFiltered
+}
+
+// IsFilteredCtx returns true if the loaded policy has been filtered.
+func (a *bunAdapter) IsFilteredCtx(ctx context.Context) bool {
+	return a.isFiltered
+}
+
+func applyFilter(query *bun.SelectQuery, filter Filter) *bun.SelectQuery {
+	if len(filter.PType) > 0 {
+		query = query.Where("ptype IN (?)", bun.In(filter.PType))
+	}
+	if len(filter.V0) > 0 {
+		query = query.Where("v0 IN (?)", bun.In(filter.V0))
+	}
+	if len(filter.V1) > 0 {
+		query = query.Where("v1 IN (?)", bun.In(filter.V1))
+	}
+	if len(filter.V2) > 0 {
+		query = query.Where("v2 IN (?)", bun.In(filter.V2))
+	}
+	if len(filter.V3) > 0 {
+		query = query.Where("v3 IN (?)", bun.In(filter.V3))
+	}
+	if len(filter.V4) > 0 {
+		query = query.Where("v4 IN (?)", bun.In(filter.V4))
+	}
+	if len(filter.V5) > 0 {
+		query = query.Where("v5 IN (?)", bun.In(filter.V5))
+	}
+	return query
+}
@@ -0,0 +1,81 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// PolicyFilter selects the rules of FindPolicies and CountPolicies with the semantics of RemoveFilteredPolicy:
+// FieldValues are compared with the fields from FieldIndex on, and an empty value matches any value.
+type PolicyFilter struct {
+	FieldIndex  int
+	FieldValues []string
+	// Limit is the number of rules that FindPolicies returns at most, 0 for no limit.
+	Limit int
+	// Cursor makes FindPolicies continue after the page that returned it. Empty starts from the first rule.
+	Cursor string
+}
+
+// FindPolicies returns the stored rules of ptype that match the filter, as LoadPolicy would load them,
+// without loading them into a model. The rules are returned in the order they were added,
+// a page of at most filter.Limit rules at a time.
+// The returned cursor continues with the next page. It is empty after a page that is not full,
+// so the rules of a table that fill the last page exactly are followed by an empty page.
+func (a *bunAdapter) FindPolicies(ctx context.Context, ptype string, filter PolicyFilter) (_ [][]string, _ string, err error) {
+	ctx, op := a.startOperation(ctx, "FindPolicies", ptypeKey.String(ptype))
+	defer func() { op.end(err) }()
+	op.addLogAttrs(slog.Int("field_index", filter.FieldIndex), slog.Int("limit", filter.Limit))
+	op.setRuleValues(slog.Any("field_values", filter.FieldValues))
+
+	var lastID int64
+	if filter.Cursor != "" {
+		if lastID, err = strconv.ParseInt(filter.Cursor, 36, 64); err != nil {
+			return nil, "", fmt.Errorf("invalid cursor %q", filter.Cursor)
+		}
+	}
+
+	query := activeQuery(a, a.selectPolicies(a.readDB()), time.Now()).
+		Where(a.equalClause("ptype"), ptype).
+		Where("id > ?", lastID).
+		Order("id")
+	query = filterFields(a, query, filter.FieldIndex, filter.FieldValues...)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	rules := make([][]string, 0)
+	if err := a.forEachPolicy(ctx, query, func(policy CasbinPolicy) error {
+		rules = append(rules, policy.filterValues())
+		lastID = policy.ID
+		return nil
+	}); err != nil {
+		return nil, "", classifyError(err)
+	}
+	op.setRuleCount(len(rules))
+
+	if filter.Limit <= 0 || len(rules) < filter.Limit {
+		return rules, "", nil
+	}
+	return rules, strconv.FormatInt(lastID, 36), nil
+}
+
+// CountPolicies returns the number of stored rules of ptype that match the filter,
+// as LoadPolicy would load them. The Limit and Cursor of the filter are ignored.
+func (a *bunAdapter) CountPolicies(ctx context.Context, ptype string, filter PolicyFilter) (_ int, err error) {
+	ctx, op := a.startOperation(ctx, "CountPolicies", ptypeKey.String(ptype))
+	defer func() { op.end(err) }()
+	op.addLogAttrs(slog.Int("field_index", filter.FieldIndex))
+	op.setRuleValues(slog.Any("field_values", filter.FieldValues))
+
+	query := activeQuery(a, a.selectPolicies(a.readDB()), time.Now()).
+		Where(a.equalClause("ptype"), ptype)
+	count, err := filterFields(a, query, filter.FieldIndex, filter.FieldValues...).Count(ctx)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	op.setRuleCount(count)
+	return count, nil
+}
@@ -0,0 +1,78 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBunAdapter_FindPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	initPolicy(t, a)
+	if err := a.AddPolicyWithExpiry(ctx, "p", "p", []string{"bob", "data3", "read"}, time.Time{}, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to add policy with expiry: %v", err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"bob", "data4", "read"}, {"bob", "data5", "write"}, {"bob", "data6", "read"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+
+	// the rules of bob that read, two at a time, without the expired one
+	filter := PolicyFilter{FieldIndex: 0, FieldValues: []string{"bob", "", "read"}, Limit: 2}
+	var pages [][][]string
+	for {
+		rules, next, err := a.FindPolicies(ctx, "p", filter)
+		if err != nil {
+			t.Fatalf("failed to find policies: %v", err)
+		}
+		pages = append(pages, rules)
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	want := [][][]string{
+		{{"bob", "data4", "read"}, {"bob", "data6", "read"}},
+		{},
+	}
+	if diff := cmp.Diff(want, pages); diff != "" {
+		t.Errorf("FindPolicies() mismatch (-want +got):\n%s", diff)
+	}
+
+	rules, next, err := a.FindPolicies(ctx, "p", PolicyFilter{FieldIndex: 1, FieldValues: []string{"data2"}})
+	if err != nil {
+		t.Fatalf("failed to find policies: %v", err)
+	}
+	if diff := cmp.Diff([][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}}, rules); diff != "" {
+		t.Errorf("FindPolicies() mismatch (-want +got):\n%s", diff)
+	}
+	if next != "" {
+		t.Errorf("got cursor %q without a limit, want none", next)
+	}
+
+	if _, _, err := a.FindPolicies(ctx, "p", PolicyFilter{Cursor: "not a cursor"}); err == nil {
+		t.Error("got no error for an invalid cursor")
+	}
+
+	tests := []struct {
+		ptype  string
+		filter PolicyFilter
+		want   int
+	}{
+		{ptype: "p", filter: PolicyFilter{}, want: 7},
+		{ptype: "p", filter: PolicyFilter{FieldValues: []string{"bob"}, Limit: 1}, want: 4},
+		{ptype: "g", filter: PolicyFilter{FieldIndex: 1, FieldValues: []string{"data2_admin"}}, want: 1},
+	}
+	for _, tt := range tests {
+		count, err := a.CountPolicies(ctx, tt.ptype, tt.filter)
+		if err != nil {
+			t.Fatalf("failed to count policies: %v", err)
+		}
+		if count != tt.want {
+			t.Errorf("CountPolicies(%s, %+v) = %d, want %d", tt.ptype, tt.filter, count, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// DefaultLockTimeout is how long the adapter waits for the lock of the policy table unless WithLockTimeout is given.
+const DefaultLockTimeout = 30 * time.Second
+
+// lockTable is the table that holds the locks on SQLite, which has no advisory locks.
+const lockTable = "casbin_lock"
+
+// lockPollInterval is how often a lock held in lockTable is tried again.
+const lockPollInterval = 20 * time.Millisecond
+
+// lockStaleAfter is how long a row of lockTable is held before it is taken to be left by a process that died,
+// and is taken over.
+const lockStaleAfter = 10 * time.Minute
+
+// lockRow is a row of casbin_lock, present while the lock of its name is held.
+type lockRow struct {
+	bun.BaseModel `bun:"casbin_lock,alias:cl"`
+	Name          string    `bun:"name,pk,type:varchar(100)"`
+	AcquiredAt    time.Time `bun:"acquired_at,notnull"`
+}
+
+// WithLockTimeout sets how long the adapter waits for the exclusive lock that it takes around
+// the creation of the table, SavePolicy, Import with ImportReplace, UpgradeSchema and MigrateFrom,
+// so that several processes starting at once do not interleave them.
+// ErrLockTimeout is returned when the lock is not acquired in time.
+// A timeout of 0 or less disables the lock.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(a *bunAdapter) {
+		a.lockTimeout = timeout
+	}
+}
+
+// lockName returns the name of the lock of the adapter's policy table.
+func (a *bunAdapter) lockName() string {
+	return "casbin:" + a.modelTable().Name
+}
+
+// withLock runs fn while holding the exclusive lock of the adapter's policy table,
+// which is shared by every process that uses the table:
+// pg_advisory_xact_lock on Postgres, GET_LOCK on MySQL, sp_getapplock on MSSQL and a row of casbin_lock on SQLite.
+// fn runs its queries on db, which is the connection or the transaction that holds the lock,
+// so that the locked operation does not need a second connection of the pool.
+func (a *bunAdapter) withLock(ctx context.Context, fn func(db bun.IDB) error) error {
+	if a.lockTimeout <= 0 {
+		return fn(a.db)
+	}
+
+	switch a.db.Dialect().Name() {
+	case dialect.PG:
+		return a.lockPostgres(ctx, fn)
+	case dialect.MySQL:
+		return a.lockMySQL(ctx, fn)
+	case dialect.MSSQL:
+		return a.lockMSSQL(ctx, fn)
+	default:
+		return a.lockSQLite(ctx, fn)
+	}
+}
+
+// lockTimeoutError returns the error for a lock that was not acquired within the timeout.
+func (a *bunAdapter) lockTimeoutError() error {
+	return fmt.Errorf("%w: %s after %s", ErrLockTimeout, a.lockName(), a.lockTimeout)
+}
+
+// lockPostgres takes a transaction-level advisory lock and runs fn in the same transaction,
+// which is committed when fn succeeds and releases the lock.
+// lock_timeout bounds the wait, failing with SQLSTATE 55P03, and is reset for fn.
+func (a *bunAdapter) lockPostgres(ctx context.Context, fn func(db bun.IDB) error) error {
+	return a.db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", a.lockTimeout.Milliseconds())); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext(?))", a.lockName()); err != nil {
+			if state, ok := postgresSQLState(err); ok && state == "55P03" {
+				return a.lockTimeoutError()
+			}
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "SET LOCAL lock_timeout = DEFAULT"); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// lockMySQL takes a named lock of the session and runs fn on its connection.
+// The timeout of the lock is counted in whole seconds.
+func (a *bunAdapter) lockMySQL(ctx context.Context, fn func(db bun.IDB) error) (err error) {
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	seconds := int(math.Ceil(a.lockTimeout.Seconds()))
+	var acquired sql.NullInt64
+	if err := conn.NewRaw("SELECT GET_LOCK(?, ?)", a.lockName(), seconds).Scan(ctx, &acquired); err != nil {
+		return err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return a.lockTimeoutError()
+	}
+	defer func() {
+		// the lock is released even when ctx is done, so that it is not left to the pooled connection
+		var released sql.NullInt64
+		if releaseErr := conn.NewRaw("SELECT RELEASE_LOCK(?)", a.lockName()).Scan(context.WithoutCancel(ctx), &released); err == nil {
+			err = releaseErr
+		}
+	}()
+	return fn(&conn)
+}
+
+// lockMSSQL takes an application lock owned by the session and runs fn on its connection.
+// sp_getapplock returns 0 or 1 when the lock is granted and -1 when it timed out.
+func (a *bunAdapter) lockMSSQL(ctx context.Context, fn func(db bun.IDB) error) (err error) {
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var result int
+	if err := conn.NewRaw(
+		"DECLARE @result int; "+
+			"EXEC @result = sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = ?; "+
+			"SELECT @result",
+		a.lockName(), a.lockTimeout.Milliseconds(),
+	).Scan(ctx, &result); err != nil {
+		return err
+	}
+	if result < 0 {
+		if result == -1 {
+			return a.lockTimeoutError()
+		}
+		return fmt.Errorf("sp_getapplock failed with %d", result)
+	}
+	defer func() {
+		// the lock is released even when ctx is done, so that it is not left to the pooled connection
+		_, releaseErr := conn.ExecContext(context.WithoutCancel(ctx), "EXEC sp_releaseapplock @Resource = ?, @LockOwner = 'Session'", a.lockName())
+		if err == nil {
+			err = releaseErr
+		}
+	}()
+	return fn(&conn)
+}
+
+// lockSQLite takes the lock by inserting its row into casbin_lock, trying again until the timeout, and runs fn on the database.
+// A process that dies while holding the lock leaves its row, which is taken over once it is older than lockStaleAfter.
+func (a *bunAdapter) lockSQLite(ctx context.Context, fn func(db bun.IDB) error) (err error) {
+	if _, err := a.db.NewCreateTable().
+		Model((*lockRow)(nil)).
+		IfNotExists().
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(a.lockTimeout)
+	for {
+		if _, err := a.db.NewDelete().
+			Model((*lockRow)(nil)).
+			Where("name = ?", a.lockName()).
+			Where("acquired_at < ?", time.Now().UTC().Add(-lockStaleAfter)).
+			Exec(ctx); err != nil {
+			return err
+		}
+		res, err := a.db.NewInsert().
+			Model(&lockRow{Name: a.lockName(), AcquiredAt: time.Now().UTC()}).
+			Ignore().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows > 0 {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return a.lockTimeoutError()
+		}
+
+		timer := time.NewTimer(min(lockPollInterval, time.Until(deadline)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	defer func() {
+		// the lock is released even when ctx is done, so that its row is not left behind
+		_, releaseErr := a.db.NewDelete().
+			Model((*lockRow)(nil)).
+			Where("name = ?", a.lockName()).
+			Exec(context.WithoutCancel(ctx))
+		if err == nil {
+			err = releaseErr
+		}
+	}()
+	return fn(a.db)
+}
@@ -0,0 +1,77 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestBunAdapter_Lock(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	a := newSQLiteAdapter(t, sqlDB, WithLockTimeout(50*time.Millisecond))
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", "testdata/rbac_policy.csv")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+	db := a.(*bunAdapter).db
+	if count, err := db.NewSelect().Table(lockTable).Count(ctx); err != nil || count != 0 {
+		t.Errorf("got %d, %v, want the lock released after SavePolicy", count, err)
+	}
+
+	// another process holds the lock
+	if _, err := db.NewInsert().Model(&lockRow{Name: "casbin:casbin_policies", AcquiredAt: time.Now().UTC()}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := a.SavePolicy(e.GetModel()); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("got %v, want ErrLockTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("gave up after %s, want the lock timeout", elapsed)
+	}
+	if _, err := a.UpgradeSchema(ctx); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("got %v, want ErrLockTimeout", err)
+	}
+	if err := a.Import(ctx, strings.NewReader("p, alice, data1, read\n"), FormatCSV, ImportReplace); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("got %v, want ErrLockTimeout", err)
+	}
+	if _, err := NewAdapterWithSqlDB(sqlDB, "sqlite3", WithLockTimeout(10*time.Millisecond)); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("got %v, want ErrLockTimeout", err)
+	}
+
+	if _, err := db.NewDelete().Model((*lockRow)(nil)).Where("1 = 1").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Errorf("failed to save policy after the lock was released: %v", err)
+	}
+
+	// the row of a process that died while holding the lock is taken over once it is stale
+	if _, err := db.NewInsert().Model(&lockRow{Name: "casbin:casbin_policies", AcquiredAt: time.Now().UTC().Add(-lockStaleAfter - time.Minute)}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Errorf("failed to save policy over a stale lock: %v", err)
+	}
+	if count, err := db.NewSelect().Table(lockTable).Count(ctx); err != nil || count != 0 {
+		t.Errorf("got %d, %v, want the stale lock released after SavePolicy", count, err)
+	}
+
+	// the lock is disabled with a timeout of 0
+	if _, err := db.NewInsert().Model(&lockRow{Name: "casbin:casbin_policies", AcquiredAt: time.Now().UTC()}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	unlocked := newSQLiteAdapter(t, sqlDB, WithLockTimeout(0))
+	if err := unlocked.SavePolicy(e.GetModel()); err != nil {
+		t.Errorf("failed to save policy: %v", err)
+	}
+}
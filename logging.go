@@ -0,0 +1,117 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// redacted replaces the rule values in the logs of an adapter without WithLogRuleValues.
+const redacted = "[redacted]"
+
+// WithLogger makes the adapter log each call of its methods to logger, at info level,
+// or at error level when the call fails, with the operation, ptype, number of rules,
+// rows affected, duration and error.
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *bunAdapter) {
+		a.logger = logger
+	}
+}
+
+// WithLogRuleValues makes the logs of WithLogger include the values of the rules, which are redacted by default.
+func WithLogRuleValues(enabled bool) Option {
+	return func(a *bunAdapter) {
+		a.logRuleValues = enabled
+	}
+}
+
+// setRuleValues adds the rule values of the method to its log, redacted unless WithLogRuleValues is set.
+func (o *operation) setRuleValues(attrs ...slog.Attr) {
+	if o == nil || o.logger == nil {
+		return
+	}
+	if !o.logRuleValues {
+		for i := range attrs {
+			attrs[i] = slog.String(attrs[i].Key, redacted)
+		}
+	}
+	o.logAttrs = append(o.logAttrs, attrs...)
+}
+
+// addLogAttrs adds the other arguments of the method to its log.
+func (o *operation) addLogAttrs(attrs ...slog.Attr) {
+	if o == nil || o.logger == nil {
+		return
+	}
+	o.logAttrs = append(o.logAttrs, attrs...)
+}
+
+// log logs the result of the method.
+func (o *operation) log(duration time.Duration, err error) {
+	if o.logger == nil {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 8+len(o.logAttrs))
+	attrs = append(attrs,
+		slog.String("operation", o.name),
+		slog.String("dialect", o.dialect))
+	if o.ptype != "" {
+		attrs = append(attrs, slog.String("ptype", o.ptype))
+	}
+	if o.ruleCount >= 0 {
+		attrs = append(attrs, slog.Int("rule_count", o.ruleCount))
+	}
+	attrs = append(attrs, o.logAttrs...)
+	attrs = append(attrs,
+		slog.Int64("rows_affected", o.rows.Load()),
+		slog.Duration("duration", duration))
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	o.logger.LogAttrs(o.ctx, level, "casbin adapter call", attrs...)
+}
+
+// QueryLogHook is a bun query hook that logs each SQL query at debug level, for example to see how a filter was translated,
+// with the adapter method that issued it when the adapter has a logger or telemetry.
+// The queries include the rule values, so the hook is meant for debugging.
+//
+//	db.AddQueryHook(casbinbunadapter.NewQueryLogHook(logger))
+type QueryLogHook struct {
+	logger *slog.Logger
+}
+
+var _ bun.QueryHook = (*QueryLogHook)(nil)
+
+// NewQueryLogHook creates a QueryLogHook that logs to logger.
+func NewQueryLogHook(logger *slog.Logger) *QueryLogHook {
+	return &QueryLogHook{logger: logger}
+}
+
+// BeforeQuery implements bun.QueryHook.
+func (h *QueryLogHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery implements bun.QueryHook.
+func (h *QueryLogHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	attrs := make([]slog.Attr, 0, 4)
+	if op, ok := ctx.Value(operationContextKey{}).(*operation); ok {
+		attrs = append(attrs, slog.String("operation", op.name))
+	}
+	attrs = append(attrs,
+		slog.String("query", event.Query),
+		slog.Duration("duration", time.Since(event.StartTime)))
+
+	level := slog.LevelDebug
+	if event.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", event.Err))
+	}
+	h.logger.LogAttrs(ctx, level, "casbin adapter query", attrs...)
+}
@@ -0,0 +1,216 @@
+package casbinbunadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"weak"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/go-cmp/cmp"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	records := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to decode log record %q: %v", line, err)
+		}
+		delete(record, "time")
+		delete(record, "duration")
+		records = append(records, record)
+	}
+	buf.Reset()
+	return records
+}
+
+func TestBunAdapter_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	a := newSQLiteAdapter(t, openSQLite(t), WithLogger(logger))
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data1", "read"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data1"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+	want := []map[string]interface{}{
+		{
+			"level": "INFO", "msg": "casbin adapter call", "operation": "AddPolicies", "dialect": "sqlite",
+			"ptype": "p", "rule_count": float64(2), "rules": redacted, "rows_affected": float64(2),
+		},
+		{
+			"level": "INFO", "msg": "casbin adapter call", "operation": "RemoveFilteredPolicy", "dialect": "sqlite",
+			"ptype": "p", "field_index": float64(1), "field_values": redacted, "rows_affected": float64(2),
+		},
+	}
+	if diff := cmp.Diff(want, logRecords(t, &buf)); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+
+	// the rule values are logged on request, and failures are logged at error level
+	a = newSQLiteAdapter(t, openSQLite(t), WithLogger(logger), WithLogRuleValues(true))
+	if err := a.AddPolicy("p", "p", []string{"a", "b", "c", "d", "e", "f", "g"}); err == nil {
+		t.Fatal("got no error for a rule with too many fields")
+	}
+	records := logRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("got %d log records, want 1", len(records))
+	}
+	if records[0]["level"] != "ERROR" || records[0]["error"] == nil {
+		t.Errorf("got %v, want an error record", records[0])
+	}
+	if diff := cmp.Diff([]interface{}{"a", "b", "c", "d", "e", "f", "g"}, records[0]["rule"]); diff != "" {
+		t.Errorf("rule mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBunAdapter_LoggerSharedDB(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// the adapters share the database, and the rows of a query are counted once
+	db := bun.NewDB(openSQLite(t), sqlitedialect.New())
+	for range 2 {
+		if _, err := NewAdapterWithBunDB(db, WithLogger(logger)); err != nil {
+			t.Fatalf("failed to create adapter: %v", err)
+		}
+	}
+	a, err := NewAdapterWithBunDB(db, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	records := logRecords(t, &buf)
+	if len(records) != 1 || records[0]["rows_affected"] != float64(1) {
+		t.Errorf("got %v, want one record with 1 row affected", records)
+	}
+}
+
+func TestBunAdapter_LoggerSharedDBCollected(t *testing.T) {
+	// a database that is no longer used is not kept alive by its rows hook
+	db := bun.NewDB(openSQLite(t), sqlitedialect.New())
+	if _, err := NewAdapterWithBunDB(db, WithLogger(slog.New(slog.DiscardHandler))); err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	key := weak.Make(db)
+	db = nil
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		runtime.GC()
+		rowsHookDBsMu.Lock()
+		_, ok := rowsHookDBs[key]
+		rowsHookDBsMu.Unlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the database is still registered after it was garbage collected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBunAdapter_LoggerRetry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// the rows of an attempt that rolled back are not counted
+	a := newSQLiteAdapter(t, openSQLite(t), WithLogger(logger), WithRetryPolicy(RetryPolicy{MaxAttempts: 2})).(*bunAdapter)
+	ctx, op := a.startOperation(context.Background(), "AddPolicy")
+	attempts := 0
+	err := a.runInTx(ctx, a.db, func(ctx context.Context, tx bun.Tx) error {
+		attempts++
+		if err := a.insertPolicies(ctx, tx, []CasbinPolicy{newCasbinPolicy("p", []string{"alice", "data1", "read"})}); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return &mysql.MySQLError{Number: 1213}
+		}
+		return nil
+	})
+	op.end(err)
+	if err != nil {
+		t.Fatalf("runInTx() error = %v", err)
+	}
+	records := logRecords(t, &buf)
+	if len(records) != 1 || records[0]["rows_affected"] != float64(1) {
+		t.Errorf("got %v, want one record with 1 row affected", records)
+	}
+}
+
+func TestBunAdapter_LoggerSchema(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	sqlDB := openSQLite(t)
+	if _, err := sqlDB.ExecContext(ctx, `CREATE TABLE casbin_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ptype varchar(100) NOT NULL,
+		v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100), v4 varchar(100), v5 varchar(100),
+		tenant_id varchar(100), namespace varchar(100), valid_from TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+	a := newSQLiteAdapter(t, sqlDB, WithLogger(logger))
+	if _, err := a.InspectSchema(ctx); err != nil {
+		t.Fatalf("failed to inspect schema: %v", err)
+	}
+	if _, err := a.UpgradeSchema(ctx); err != nil {
+		t.Fatalf("failed to upgrade schema: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"level": "INFO", "msg": "casbin adapter call", "operation": "InspectSchema", "dialect": "sqlite", "differences": float64(1), "rows_affected": float64(0)},
+		{
+			"level": "INFO", "msg": "casbin adapter call", "operation": "UpgradeSchema", "dialect": "sqlite",
+			"applied": []interface{}{"expires_at: missing, want TIMESTAMP"}, "unsafe": float64(0), "rows_affected": float64(0),
+		},
+	}
+	if diff := cmp.Diff(want, logRecords(t, &buf)); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueryLogHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	a := newSQLiteAdapter(t, openSQLite(t), WithLogger(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))))
+	a.(*bunAdapter).db.AddQueryHook(NewQueryLogHook(logger))
+	if err := a.RemoveFilteredPolicy("p", "p", 1, "data1", ""); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+
+	// the DELETE runs in a transaction with the update of the revision
+	var deletes []map[string]interface{}
+	for _, record := range logRecords(t, &buf) {
+		if record["level"] != "DEBUG" || record["operation"] != "RemoveFilteredPolicy" {
+			t.Errorf("got %v, want a debug record of RemoveFilteredPolicy", record)
+		}
+		if query, _ := record["query"].(string); strings.HasPrefix(query, "DELETE FROM") {
+			deletes = append(deletes, record)
+		}
+	}
+	if len(deletes) != 1 {
+		t.Fatalf("got %d DELETE records, want 1", len(deletes))
+	}
+	query, _ := deletes[0]["query"].(string)
+	if !strings.Contains(query, "'data1'") || !strings.Contains(query, "v2 LIKE '%'") {
+		t.Errorf("got query %q, want the DELETE of the filter", query)
+	}
+}
@@ -0,0 +1,232 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// TableLayout describes a policy table of another layout, like the casbin_rule table of gorm-adapter.
+type TableLayout struct {
+	// Table is the name of the table.
+	Table string
+	// PType is the column that holds the ptype.
+	PType string
+	// Values are the columns that hold the values of a rule, in order. At most 6 are allowed.
+	Values []string
+}
+
+func (l TableLayout) validate() error {
+	if l.Table == "" || l.PType == "" {
+		return errors.New("table layout needs a table and a ptype column")
+	}
+	if len(l.Values) > maxRuleLength {
+		return fmt.Errorf("%w: table layout has %d value columns, at most %d are stored", ErrTooManyFields, len(l.Values), maxRuleLength)
+	}
+	return nil
+}
+
+// GormAdapterLayout is the layout of the casbin_rule table of gorm-adapter v3.
+var GormAdapterLayout = TableLayout{
+	Table:  "casbin_rule",
+	PType:  "ptype",
+	Values: []string{"v0", "v1", "v2", "v3", "v4", "v5"},
+}
+
+// XormAdapterLayout is the layout of the casbin_rule table of xorm-adapter, and of gorm-adapter before v3.
+var XormAdapterLayout = TableLayout{
+	Table:  "casbin_rule",
+	PType:  "p_type",
+	Values: []string{"v0", "v1", "v2", "v3", "v4", "v5"},
+}
+
+// MigrateOptions changes how MigrateFrom copies the rules.
+type MigrateOptions struct {
+	// DryRun rolls the transaction back at the end, so that the report tells what a migration would do.
+	DryRun bool
+	// Verify compares the rules of the policy table with the rules of the source table after copying,
+	// by row count and by a SHA-256 hash of the content, and rolls back on a mismatch.
+	// The policy table must then hold only the migrated rules, as when migrating into an empty table.
+	Verify bool
+}
+
+// MigrateReport tells what MigrateFrom did, or would have done on a dry run.
+type MigrateReport struct {
+	// Read is the number of rows read from the source table.
+	Read int
+	// Copied is the number of rules inserted into the policy table.
+	Copied int
+	// Skipped is the number of rows whose rule was already stored, by an earlier run or by an earlier row.
+	Skipped int
+	// Verified is true when Verify found the policy table and the source table to match.
+	Verified bool
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// MigrateFrom copies the rules of a table of another layout into the policy table in one transaction.
+// Rules that are already stored are skipped, so running a migration again copies only the rows added since.
+// NULL values in the source table are copied as empty strings.
+func (a *bunAdapter) MigrateFrom(ctx context.Context, layout TableLayout, opts MigrateOptions) (_ MigrateReport, err error) {
+	ctx, op := a.startOperation(ctx, "MigrateFrom")
+	defer func() { op.end(err) }()
+
+	if err := layout.validate(); err != nil {
+		return MigrateReport{}, err
+	}
+
+	var report MigrateReport
+	err = a.withLock(ctx, func(db bun.IDB) error {
+		return a.runInTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+			report = MigrateReport{}
+			// the copied rules are not in the model of any enforcer, so its SavePolicy must fail
+			if _, err := a.bumpRevision(ctx, tx, anyRevision); err != nil {
+				return err
+			}
+			policies, err := a.readLayout(ctx, tx, layout)
+			if err != nil {
+				return err
+			}
+			if err := a.checkWidth(policies...); err != nil {
+				return err
+			}
+			report.Read = len(policies)
+
+			stored, err := a.storedPoliciesInTx(ctx, tx)
+			if err != nil {
+				return err
+			}
+			seen := make(map[CasbinPolicy]struct{}, len(stored)+len(policies))
+			for _, policy := range stored {
+				seen[policy] = struct{}{}
+			}
+			batch := make([]CasbinPolicy, 0, len(policies))
+			for _, policy := range policies {
+				if _, ok := seen[policy]; ok {
+					report.Skipped++
+					continue
+				}
+				seen[policy] = struct{}{}
+				batch = append(batch, policy)
+			}
+
+			for start := 0; start < len(batch); start += importBatchSize {
+				chunk := batch[start:min(start+importBatchSize, len(batch))]
+				if err := a.insertPolicies(ctx, tx, chunk); err != nil {
+					return err
+				}
+			}
+			report.Copied = len(batch)
+
+			if opts.Verify {
+				if err := a.verifyMigration(ctx, tx, policies); err != nil {
+					return err
+				}
+				report.Verified = true
+			}
+			if opts.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return MigrateReport{}, err
+	}
+	op.setRuleCount(report.Read)
+	return report, nil
+}
+
+// storedPoliciesInTx returns the policies visible to the adapter, without their ids.
+func (a *bunAdapter) storedPoliciesInTx(ctx context.Context, tx bun.Tx) ([]CasbinPolicy, error) {
+	query := tx.NewSelect().
+		Model(a.tableModel())
+	policies, err := a.collectPolicies(ctx, scopeQuery(a, excludeColumns(a, query)))
+	if err != nil {
+		return nil, err
+	}
+	for i := range policies {
+		policies[i].ID = 0
+	}
+	return policies, nil
+}
+
+// verifyMigration checks that the policy table holds exactly the distinct rules of the source table.
+func (a *bunAdapter) verifyMigration(ctx context.Context, tx bun.Tx, source []CasbinPolicy) error {
+	stored, err := a.storedPoliciesInTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	distinct := make(map[CasbinPolicy]struct{}, len(source))
+	for _, policy := range source {
+		distinct[policy] = struct{}{}
+	}
+	want := make([]CasbinPolicy, 0, len(distinct))
+	for policy := range distinct {
+		want = append(want, policy)
+	}
+
+	wantHash, gotHash := contentHash(want), contentHash(stored)
+	if len(want) != len(stored) || wantHash != gotHash {
+		return fmt.Errorf("%w: source has %d rules with hash %s, policy table has %d with hash %s",
+			ErrMigrationMismatch, len(want), wantHash, len(stored), gotHash)
+	}
+	return nil
+}
+
+// contentHash returns a SHA-256 hash of the rules that does not depend on their order.
+func contentHash(policies []CasbinPolicy) string {
+	lines := make([]string, 0, len(policies))
+	for _, p := range policies {
+		lines = append(lines, strings.Join([]string{p.PType, p.V0, p.V1, p.V2, p.V3, p.V4, p.V5}, "\x00"))
+	}
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readLayout reads all rules of the table of another layout.
+// The rows are held in memory, since the transaction cannot insert while a cursor is open.
+func (a *bunAdapter) readLayout(ctx context.Context, tx bun.Tx, layout TableLayout) ([]CasbinPolicy, error) {
+	query := tx.NewSelect().
+		TableExpr("?", bun.Ident(layout.Table)).
+		ColumnExpr("COALESCE(?, '') AS ptype", bun.Ident(layout.PType))
+	for i, column := range layout.Values {
+		query = query.ColumnExpr("COALESCE(?, '') AS ?", bun.Ident(column), bun.Ident(fmt.Sprintf("v%d", i)))
+	}
+
+	var rows []struct {
+		PType string `bun:"ptype"`
+		V0    string `bun:"v0"`
+		V1    string `bun:"v1"`
+		V2    string `bun:"v2"`
+		V3    string `bun:"v3"`
+		V4    string `bun:"v4"`
+		V5    string `bun:"v5"`
+	}
+	if err := query.Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	policies := make([]CasbinPolicy, 0, len(rows))
+	for _, row := range rows {
+		policy, err := a.newPolicy(row.PType, []string{row.V0, row.V1, row.V2, row.V3, row.V4, row.V5})
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
@@ -0,0 +1,158 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// PolicyModel is implemented by a struct that stores the policy rules in place of CasbinPolicy,
+// for example to add columns like created_by or JSON metadata to the rows.
+//
+// The struct must be a bun model whose table has the id, ptype and v0 to v5 columns of CasbinPolicy,
+// the tenant_id column if the adapter is scoped to a tenant, and the namespace column if it is scoped to a namespace.
+// Its other columns are left to the struct, which can fill them in SetPolicyRule
+// or in a bun hook like BeforeAppendModel, except for valid_from and expires_at:
+// rules can have an expiry when the struct has both as bun.NullTime fields.
+type PolicyModel interface {
+	// PolicyRule returns the ptype and the values of the rule stored in the row.
+	PolicyRule() (ptype string, values []string)
+	// SetPolicyRule sets the ptype and the values, at most 6, of the rule stored in the row.
+	SetPolicyRule(ptype string, values []string)
+}
+
+// PolicyRule returns the ptype and the values of the rule stored in the row.
+func (c CasbinPolicy) PolicyRule() (string, []string) {
+	return c.PType, c.ruleValues()
+}
+
+// SetPolicyRule sets the ptype and the values of the rule stored in the row.
+func (c *CasbinPolicy) SetPolicyRule(ptype string, values []string) {
+	policy := newCasbinPolicy(ptype, values)
+	policy.ID, policy.TenantID, policy.Namespace = c.ID, c.TenantID, c.Namespace
+	policy.ValidFrom, policy.ExpiresAt = c.ValidFrom, c.ExpiresAt
+	*c = policy
+}
+
+var casbinPolicyType = reflect.TypeOf(CasbinPolicy{})
+
+// NewAdapterWithModel creates an adapter that stores the policy rules in the table of model,
+// a pointer to a struct that implements PolicyModel, instead of in casbin_policies.
+func NewAdapterWithModel(db *bun.DB, model PolicyModel, opts ...Option) (Adapter, error) {
+	typ := reflect.TypeOf(model)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("policy model must be a pointer to a struct, got %T", model)
+	}
+	table := db.Table(typ.Elem())
+	for _, column := range append([]string{"id"}, ruleColumns...) {
+		if _, ok := table.FieldMap[column]; !ok {
+			return nil, fmt.Errorf("policy model %s has no %s column", typ.Elem(), column)
+		}
+	}
+
+	return newAdapter(db, append([]Option{func(a *bunAdapter) {
+		a.modelType = typ.Elem()
+	}}, opts...)...)
+}
+
+// modelTable returns the bun table of the policy model.
+func (a *bunAdapter) modelTable() *schema.Table {
+	return a.db.Table(a.modelType)
+}
+
+// tableModel returns the model of the queries that do not read or write a policy, like deletes.
+func (a *bunAdapter) tableModel() interface{} {
+	return reflect.Zero(reflect.PointerTo(a.modelType)).Interface()
+}
+
+// hasColumn reports whether the table of the policy model has the column.
+func (a *bunAdapter) hasColumn(column string) bool {
+	_, ok := a.modelTable().FieldMap[column]
+	return ok
+}
+
+// toModel converts the policy into the policy model.
+func (a *bunAdapter) toModel(policy CasbinPolicy) interface{} {
+	if a.modelType == casbinPolicyType {
+		return &policy
+	}
+	m := reflect.New(a.modelType)
+	m.Interface().(PolicyModel).SetPolicyRule(policy.PType, policy.ruleValues())
+	a.setTimeBounds(m.Elem(), policy)
+	return m.Interface()
+}
+
+// toModels converts the policies into a pointer to a slice of the policy model.
+func (a *bunAdapter) toModels(policies []CasbinPolicy) interface{} {
+	if a.modelType == casbinPolicyType {
+		return &policies
+	}
+	models := reflect.MakeSlice(reflect.SliceOf(a.modelType), len(policies), len(policies))
+	for i, policy := range policies {
+		models.Index(i).Addr().Interface().(PolicyModel).SetPolicyRule(policy.PType, policy.ruleValues())
+		a.setTimeBounds(models.Index(i), policy)
+	}
+	ptr := reflect.New(models.Type())
+	ptr.Elem().Set(models)
+	return ptr.Interface()
+}
+
+// scanPolicy scans the current row into the policy model and converts it into a CasbinPolicy.
+func (a *bunAdapter) scanPolicy(scan func(dest interface{}) error) (CasbinPolicy, error) {
+	if a.modelType == casbinPolicyType {
+		var policy CasbinPolicy
+		err := scan(&policy)
+		return policy, err
+	}
+
+	m := reflect.New(a.modelType)
+	if err := scan(m.Interface()); err != nil {
+		return CasbinPolicy{}, err
+	}
+	ptype, values := m.Interface().(PolicyModel).PolicyRule()
+	policy := newCasbinPolicy(ptype, values)
+	policy.TenantID, policy.Namespace = a.tenantID, a.namespace
+	if id := a.modelTable().FieldMap["id"].Value(m.Elem()); id.CanInt() {
+		policy.ID = id.Int()
+	}
+	a.getTimeBounds(m.Elem(), &policy)
+	return policy, nil
+}
+
+// collectPolicies runs the query and returns the policies it selects.
+func (a *bunAdapter) collectPolicies(ctx context.Context, query *bun.SelectQuery) ([]CasbinPolicy, error) {
+	policies := make([]CasbinPolicy, 0)
+	if err := a.forEachPolicy(ctx, query, func(policy CasbinPolicy) error {
+		policies = append(policies, policy)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// insertPolicies inserts the policies through the policy model, setting the tenant and namespace of the adapter.
+func (a *bunAdapter) insertPolicies(ctx context.Context, db bun.IDB, policies []CasbinPolicy) error {
+	if len(policies) == 0 {
+		return nil
+	}
+	_, err := a.insertQuery(db, policies).Exec(ctx)
+	return err
+}
+
+// insertQuery returns the query that inserts the policies through the policy model,
+// setting the tenant and namespace of the adapter.
+func (a *bunAdapter) insertQuery(db bun.IDB, policies []CasbinPolicy) *bun.InsertQuery {
+	query := db.NewInsert().
+		Model(a.toModels(policies))
+	if a.tenantID != "" {
+		query = query.Value(tenantColumn, "?", a.tenantID)
+	}
+	if a.namespace != "" {
+		query = query.Value(namespaceColumn, "?", a.namespace)
+	}
+	return excludeColumns(a, query)
+}
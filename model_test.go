@@ -0,0 +1,104 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+type auditedPolicy struct {
+	bun.BaseModel `bun:"table:audited_policies,alias:ap"`
+
+	ID        int64  `bun:"id,pk,autoincrement"`
+	Kind      string `bun:"ptype,type:varchar(100),notnull"`
+	Subject   string `bun:"v0,type:varchar(100)"`
+	Object    string `bun:"v1,type:varchar(100)"`
+	Action    string `bun:"v2,type:varchar(100)"`
+	V3        string `bun:"v3,type:varchar(100)"`
+	V4        string `bun:"v4,type:varchar(100)"`
+	V5        string `bun:"v5,type:varchar(100)"`
+	CreatedBy string `bun:"created_by,type:varchar(100)"`
+}
+
+func (p auditedPolicy) PolicyRule() (string, []string) {
+	return p.Kind, []string{p.Subject, p.Object, p.Action, p.V3, p.V4, p.V5}
+}
+
+func (p *auditedPolicy) SetPolicyRule(ptype string, values []string) {
+	values = append(values, make([]string, maxRuleLength)...)
+	p.Kind = ptype
+	p.Subject, p.Object, p.Action, p.V3, p.V4, p.V5 = values[0], values[1], values[2], values[3], values[4], values[5]
+	p.CreatedBy = "test"
+}
+
+func TestNewAdapterWithModel(t *testing.T) {
+	ctx := context.Background()
+	db := bun.NewDB(openSQLite(t), sqlitedialect.New())
+	a, err := NewAdapterWithModel(db, (*auditedPolicy)(nil))
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	initPolicy(t, a)
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}})
+
+	if _, err := e.AddPolicy("carol", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if _, err := e.UpdatePolicy([]string{"bob", "data2", "write"}, []string{"bob", "data2", "read"}); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if _, err := e.RemovePolicy("alice", "data1", "read"); err != nil {
+		t.Fatalf("failed to remove policy: %v", err)
+	}
+	if _, err := e.RemoveFilteredPolicy(0, "data2_admin"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"bob", "data2", "read"}, {"carol", "data3", "read"}})
+
+	var rows []auditedPolicy
+	if err := db.NewSelect().Model(&rows).Where("ptype = ?", "p").Order("id").Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	for _, row := range rows {
+		if row.CreatedBy != "test" {
+			t.Errorf("got created_by %q for %v, want test", row.CreatedBy, row)
+		}
+	}
+
+	if _, err := NewAdapterWithModel(db, (*CasbinPolicy)(nil)); err != nil {
+		t.Errorf("failed to create adapter with CasbinPolicy: %v", err)
+	}
+}
+
+type incompletePolicy struct {
+	ID    int64  `bun:"id,pk,autoincrement"`
+	PType string `bun:"ptype"`
+}
+
+func (p incompletePolicy) PolicyRule() (string, []string) { return p.PType, nil }
+
+func (p *incompletePolicy) SetPolicyRule(ptype string, values []string) { p.PType = ptype }
+
+func TestNewAdapterWithModel_Invalid(t *testing.T) {
+	db := bun.NewDB(openSQLite(t), sqlitedialect.New())
+	if _, err := NewAdapterWithModel(db, (*incompletePolicy)(nil)); err == nil {
+		t.Error("got no error for a model without the v0 to v5 columns")
+	}
+	if _, err := NewAdapterWithModel(db, nil); err == nil {
+		t.Error("got no error for a nil model")
+	}
+}
@@ -0,0 +1,24 @@
+package casbinbunadapter
+
+// namespaceColumn is the column that holds the namespace of a policy rule.
+const namespaceColumn = "namespace"
+
+// WithNamespace scopes the adapter to the rules of one casbin model, so that the enforcers of several models,
+// like an RBAC model of an API and an ABAC model of documents, can share one policy table.
+// Every query the adapter issues is restricted to the rows of that namespace,
+// and SavePolicy replaces only that namespace's rows instead of every row of the table.
+// A namespace can be combined with a tenant, scoping the adapter to the rows of both.
+// An adapter without a namespace reads and writes the rows that have no namespace.
+// The namespace must fit in the 100 characters of the namespace column, or the constructors fail with a *RuleTooLongError.
+func WithNamespace(namespace string) Option {
+	return func(a *bunAdapter) {
+		a.namespace = namespace
+	}
+}
+
+// scoped reports whether the queries of the adapter are restricted to some of the rows of the table,
+// rather than to the whole table.
+func (a *bunAdapter) scoped() bool {
+	conditions, _ := a.scopeConditions()
+	return len(conditions) > 0
+}
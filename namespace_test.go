@@ -0,0 +1,133 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBunAdapter_Namespace(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	api := newSQLiteAdapter(t, sqlDB, WithNamespace("api"))
+	docs := newSQLiteAdapter(t, sqlDB, WithNamespace("docs"))
+	docsTenant := newSQLiteAdapter(t, sqlDB, WithNamespace("docs"), WithTenant("tenant-a"))
+
+	// 1. SavePolicy of one namespace does not touch the other namespace
+	initPolicy(t, api)
+	initPolicy(t, docs)
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", docs)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	e.ClearPolicy()
+	if err := e.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+
+	e, err = casbin.NewEnforcer("testdata/rbac_model.conf", api)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+
+	// 2. Auto-Save operations are scoped to the namespace
+	initPolicy(t, docs)
+	if err := docsTenant.AddPolicy("p", "p", []string{"carol", "doc1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if _, err := e.RemoveFilteredPolicy(0, "data2_admin"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+	if _, err := e.UpdatePolicy([]string{"alice", "data1", "read"}, []string{"alice", "data1", "write"}); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "write"}, {"bob", "data2", "write"}})
+
+	count, err := docs.CountPolicies(ctx, "p", PolicyFilter{})
+	if err != nil {
+		t.Fatalf("failed to count policies: %v", err)
+	}
+	// the rules of the docs namespace without a tenant, leaving out the one of tenant-a
+	if count != 4 {
+		t.Errorf("got %d rules in the docs namespace, want 4", count)
+	}
+	rules, _, err := docsTenant.FindPolicies(ctx, "p", PolicyFilter{})
+	if err != nil {
+		t.Fatalf("failed to find policies: %v", err)
+	}
+	if diff := cmp.Diff([][]string{{"carol", "doc1", "read"}}, rules); diff != "" {
+		t.Errorf("FindPolicies() mismatch (-want +got):\n%s", diff)
+	}
+
+	// 3. the same rule in two namespaces is not a duplicate
+	if err := docs.AddPolicy("p", "p", []string{"alice", "data1", "write"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	deleted, err := docs.Deduplicate(ctx)
+	if err != nil {
+		t.Fatalf("failed to deduplicate: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("got %d duplicate rows deleted, want 0", deleted)
+	}
+	added, err := api.AddPoliciesIgnoringDuplicates(ctx, "p", "p", [][]string{{"alice", "data1", "write"}, {"data2_admin", "data2", "read"}})
+	if err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if diff := cmp.Diff([][]string{{"data2_admin", "data2", "read"}}, added); diff != "" {
+		t.Errorf("AddPoliciesIgnoringDuplicates() mismatch (-want +got):\n%s", diff)
+	}
+
+	// 4. an adapter without a namespace does not see the rows of the namespaces
+	all := newSQLiteAdapter(t, sqlDB)
+	if count, err = all.CountPolicies(ctx, "p", PolicyFilter{}); err != nil {
+		t.Fatalf("failed to count policies: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("got %d rules without a namespace, want 0", count)
+	}
+}
+
+func TestBunAdapter_NamespaceShared(t *testing.T) {
+	sqlDB := openSQLite(t)
+	docs := newSQLiteAdapter(t, sqlDB, WithNamespace("docs"))
+	shared := newSQLiteAdapter(t, sqlDB)
+	initPolicy(t, docs)
+	initPolicy(t, shared)
+
+	// SavePolicy of the adapter without a namespace leaves the rules of the docs namespace
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", shared)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	e.ClearPolicy()
+	if err := e.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{})
+
+	e, err = casbin.NewEnforcer("testdata/rbac_model.conf", docs)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+}
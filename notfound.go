@@ -0,0 +1,51 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// WithPolicyNotFound makes RemovePolicy, RemovePolicies, UpdatePolicy and UpdatePolicies fail with ErrPolicyNotFound,
+// rolling back the whole call, when a rule matches no stored row.
+// It is off by default because casbin calls the adapter before it changes its model,
+// so that removing a rule that is in the model but not in the database would fail instead of removing it from the model.
+func WithPolicyNotFound(enabled bool) Option {
+	return func(a *bunAdapter) {
+		a.policyNotFound = enabled
+	}
+}
+
+// checkFound returns ErrPolicyNotFound for a remove or an update of the policy that matched no row,
+// if the adapter was created with WithPolicyNotFound.
+func (a *bunAdapter) checkFound(ctx context.Context, tx bun.Tx, res sql.Result, policy CasbinPolicy) error {
+	if !a.policyNotFound {
+		return nil
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	if a.db.Dialect().Name() == dialect.MySQL {
+		// MySQL counts the rows that an update changed rather than the ones it matched,
+		// so a rule updated to the values it already had is looked up
+		query := tx.NewSelect().
+			Model(a.tableModel()).
+			Where(a.equalClause("ptype"), policy.PType)
+		for key, value := range policy.filterValuesWithKey() {
+			query = query.Where(a.equalClause(key), value)
+		}
+		exists, err := scopeQuery(a, query).Exists(ctx)
+		if err != nil || exists {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s %v", ErrPolicyNotFound, policy.PType, policy.ruleValues())
+}
@@ -0,0 +1,59 @@
+package casbinbunadapter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestBunAdapter_PolicyNotFound(t *testing.T) {
+	sqlDB := openSQLite(t)
+	a := newSQLiteAdapter(t, sqlDB, WithPolicyNotFound(true))
+	initPolicy(t, a)
+
+	// 1. removes and updates of a rule that is not stored
+	if err := a.RemovePolicy("p", "p", []string{"carol", "data3", "read"}); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got %v, want ErrPolicyNotFound", err)
+	}
+	if err := a.UpdatePolicy("p", "p", []string{"carol", "data3", "read"}, []string{"carol", "data3", "write"}); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got %v, want ErrPolicyNotFound", err)
+	}
+
+	// 2. the rules of the call that were found are not changed either
+	err := a.UpdatePolicies(
+		"p",
+		"p",
+		[][]string{{"alice", "data1", "read"}, {"carol", "data3", "read"}},
+		[][]string{{"alice", "data1", "write"}, {"carol", "data3", "write"}},
+	)
+	if !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got %v, want ErrPolicyNotFound", err)
+	}
+	if err := a.RemovePolicies("p", "p", [][]string{{"bob", "data2", "write"}, {"carol", "data3", "read"}}); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("got %v, want ErrPolicyNotFound", err)
+	}
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+
+	// 3. a rule updated to the values it has is found
+	if err := a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "read"}); err != nil {
+		t.Errorf("failed to update policy: %v", err)
+	}
+	if err := a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Errorf("failed to remove policy: %v", err)
+	}
+
+	// 4. without the option, a rule that is not stored is ignored
+	b := newSQLiteAdapter(t, sqlDB)
+	if err := b.RemovePolicy("p", "p", []string{"carol", "data3", "read"}); err != nil {
+		t.Errorf("failed to remove policy: %v", err)
+	}
+}
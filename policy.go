@@ -2,18 +2,25 @@ package casbinbunadapter
 
 import "github.com/uptrace/bun"
 
+// maxRuleLength is the number of values that fit into the v0 to v5 columns.
+const maxRuleLength = 6
+
 // Database storage format following the below
 // https://casbin.org/docs/policy-storage#database-storage-format
 type CasbinPolicy struct {
 	bun.BaseModel `bun:"casbin_policies,alias:cp"`
-	ID            int64  `bun:"id,pk,autoincrement"`
-	PType         string `bun:"ptype,type:varchar(100),notnull"`
-	V0            string `bun:"v0,type:varchar(100)"`
-	V1            string `bun:"v1,type:varchar(100)"`
-	V2            string `bun:"v2,type:varchar(100)"`
-	V3            string `bun:"v3,type:varchar(100)"`
-	V4            string `bun:"v4,type:varchar(100)"`
-	V5            string `bun:"v5,type:varchar(100)"`
+	ID            int64        `bun:"id,pk,autoincrement"`
+	PType         string       `bun:"ptype,type:varchar(100),notnull"`
+	V0            string       `bun:"v0,type:varchar(100)"`
+	V1            string       `bun:"v1,type:varchar(100)"`
+	V2            string       `bun:"v2,type:varchar(100)"`
+	V3            string       `bun:"v3,type:varchar(100)"`
+	V4            string       `bun:"v4,type:varchar(100)"`
+	V5            string       `bun:"v5,type:varchar(100)"`
+	TenantID      string       `bun:"tenant_id,type:varchar(100),nullzero"`
+	Namespace     string       `bun:"namespace,type:varchar(100),nullzero"`
+	ValidFrom     bun.NullTime `bun:"valid_from,nullzero"`
+	ExpiresAt     bun.NullTime `bun:"expires_at,nullzero"`
 }
 
 func (c CasbinPolicy) toSlice() []string {
@@ -66,6 +73,16 @@ func (c CasbinPolicy) filterValues() []string {
 	return values
 }
 
+// ruleValues returns the values of the rule up to the last non-empty one.
+// Unlike filterValues, empty values between non-empty ones are kept in place.
+func (c CasbinPolicy) ruleValues() []string {
+	values := []string{c.V0, c.V1, c.V2, c.V3, c.V4, c.V5}
+	for len(values) > 0 && values[len(values)-1] == "" {
+		values = values[:len(values)-1]
+	}
+	return values
+}
+
 func (c CasbinPolicy) filterValuesWithKey() map[string]string {
 	values := make(map[string]string)
 	if c.V0 != "" {
@@ -382,3 +382,69 @@ func TestCasbinPolicy_filterValuesWithKey(t *testing.T) {
 		})
 	}
 }
+
+func TestCasbinPolicy_ruleValues(t *testing.T) {
+	type fields struct {
+		v0 string
+		v1 string
+		v2 string
+		v3 string
+		v4 string
+		v5 string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   []string
+	}{
+		{
+			name:   "success when no rules are provided",
+			fields: fields{},
+			want:   []string{},
+		},
+		{
+			name: "success when three rules are provided",
+			fields: fields{
+				v0: "alice",
+				v1: "data1",
+				v2: "read",
+			},
+			want: []string{"alice", "data1", "read"},
+		},
+		{
+			name: "success when an empty rule is between non-empty rules",
+			fields: fields{
+				v0: "alice",
+				v2: "read",
+			},
+			want: []string{"alice", "", "read"},
+		},
+		{
+			name: "success when six rules are provided",
+			fields: fields{
+				v0: "alice",
+				v1: "data1",
+				v2: "read",
+				v3: "allow",
+				v4: "1",
+				v5: "2",
+			},
+			want: []string{"alice", "data1", "read", "allow", "1", "2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := CasbinPolicy{
+				V0: tt.fields.v0,
+				V1: tt.fields.v1,
+				V2: tt.fields.v2,
+				V3: tt.fields.v3,
+				V4: tt.fields.v4,
+				V5: tt.fields.v5,
+			}
+			if diff := cmp.Diff(tt.want, policy.ruleValues()); diff != "" {
+				t.Errorf("ruleValues() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
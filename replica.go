@@ -0,0 +1,88 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// replicaSet routes the reads of an adapter to read replicas in turn.
+type replicaSet struct {
+	dbs  []*bun.DB
+	next atomic.Uint64
+
+	// reads go to the primary for stickyFor after the last write
+	stickyFor time.Duration
+	lastWrite atomic.Int64
+	now       func() time.Time
+}
+
+// NewAdapterWithReplicas creates an adapter that sends the loads to the replicas in turn
+// and every mutation to the primary.
+// Selects that run inside a transaction, like the one in UpdateFilteredPolicies, stay on the primary.
+func NewAdapterWithReplicas(primary *bun.DB, replicas []*bun.DB, opts ...Option) (Adapter, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("at least one replica is required")
+	}
+
+	b, err := newAdapter(primary, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	b.replicas = &replicaSet{
+		dbs:       replicas,
+		stickyFor: b.readYourWrites,
+		now:       time.Now,
+	}
+	if b.readYourWrites > 0 {
+		primary.AddQueryHook(b.replicas)
+	}
+
+	return b, nil
+}
+
+// WithReadYourWrites sends the reads to the primary for the given duration after a write,
+// so that a load right after a write sees the written rules despite replication lag.
+// It has an effect only on adapters created by NewAdapterWithReplicas.
+func WithReadYourWrites(d time.Duration) Option {
+	return func(a *bunAdapter) {
+		a.readYourWrites = d
+	}
+}
+
+// readDB returns the database that serves the reads of an operation of the adapter.
+// Each call may return another replica, so an operation that reads several times calls it once.
+func (a *bunAdapter) readDB() *bun.DB {
+	if a.replicas == nil {
+		return a.db
+	}
+	return a.replicas.pick(a.db)
+}
+
+func (r *replicaSet) pick(primary *bun.DB) *bun.DB {
+	if r.stickyFor > 0 {
+		lastWrite := r.lastWrite.Load()
+		if lastWrite != 0 && r.now().Sub(time.Unix(0, lastWrite)) < r.stickyFor {
+			return primary
+		}
+	}
+	n := r.next.Add(1)
+	return r.dbs[int(n%uint64(len(r.dbs)))]
+}
+
+// BeforeQuery implements bun.QueryHook.
+func (r *replicaSet) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+// AfterQuery implements bun.QueryHook and records the time of every write to the primary.
+func (r *replicaSet) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if event.Operation() == "SELECT" {
+		return
+	}
+	r.lastWrite.Store(r.now().UnixNano())
+}
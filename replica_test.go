@@ -0,0 +1,62 @@
+package casbinbunadapter
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+func TestBunAdapter_Replicas(t *testing.T) {
+	replicaDB := bun.NewDB(openSQLite(t), sqlitedialect.New())
+	replica, err := NewAdapterWithBunDB(replicaDB)
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	initPolicy(t, replica)
+	// the adapter that seeded the replica does not own replicaDB, so collecting it leaves replicaDB open
+	replica = nil
+	runtime.GC()
+
+	primaryDB := bun.NewDB(openSQLite(t), sqlitedialect.New())
+	a, err := NewAdapterWithReplicas(primaryDB, []*bun.DB{replicaDB}, WithReadYourWrites(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	now := time.Now()
+	a.(*bunAdapter).replicas.now = func() time.Time { return now }
+
+	// 1. loads are served by the replica
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+
+	// 2. writes go to the primary, and reads follow them for a while
+	if _, err := e.AddPolicy("jack", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"jack", "data3", "read"}})
+
+	// 3. reads return to the replica once the window has passed
+	now = now.Add(time.Minute)
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+}
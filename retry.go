@@ -0,0 +1,140 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun"
+)
+
+// RetryPolicy decides whether a failed transaction is run again, and when.
+// A transaction is always re-run as a whole, never from the statement that failed.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	MaxAttempts int
+	// Backoff returns how long to wait before the given attempt, counting from 2.
+	// Nil means no wait.
+	Backoff func(attempt int) time.Duration
+	// Retryable classifies the errors that are worth another attempt.
+	// Nil means IsRetryable.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy makes up to 5 attempts with an exponential backoff from 10ms to 1s.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     ExponentialBackoff(10*time.Millisecond, time.Second),
+	Retryable:   IsRetryable,
+}
+
+// WithRetryPolicy re-runs the transactions of the adapter when they fail with an error that the policy classifies as retryable.
+// Retries stop as soon as the caller's context is done.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(a *bunAdapter) {
+		a.retryPolicy = policy
+	}
+}
+
+// ExponentialBackoff returns a backoff that doubles from base for each attempt, up to limit.
+func ExponentialBackoff(base, limit time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 2; i < attempt && d < limit; i++ {
+			d *= 2
+		}
+		if d > limit {
+			return limit
+		}
+		return d
+	}
+}
+
+// IsRetryable reports whether err is a deadlock or a serialization failure,
+// after which running the same transaction again can succeed.
+// It recognizes MySQL 1213, Postgres 40001 and 40P01, and MSSQL 1205.
+func IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213
+	}
+	if state, ok := postgresSQLState(err); ok {
+		return state == "40001" || state == "40P01"
+	}
+	if number, ok := mssqlErrorNumber(err); ok {
+		return number == 1205
+	}
+	return false
+}
+
+// postgresSQLState returns the SQLSTATE of errors from pgdriver and pgx.
+func postgresSQLState(err error) (string, bool) {
+	var pgdriverErr interface{ Field(k byte) string }
+	if errors.As(err, &pgdriverErr) {
+		return pgdriverErr.Field('C'), true
+	}
+	var pgxErr interface{ SQLState() string }
+	if errors.As(err, &pgxErr) {
+		return pgxErr.SQLState(), true
+	}
+	return "", false
+}
+
+// mssqlErrorNumber returns the error number of errors from go-mssqldb.
+func mssqlErrorNumber(err error) (int32, bool) {
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.SQLErrorNumber(), true
+	}
+	return 0, false
+}
+
+// runInTx runs fn in a transaction of db, re-running the whole transaction on retryable errors.
+// On a transaction that is already open, like the one that holds the lock on Postgres, it runs fn in a savepoint.
+// The error of the last attempt is returned as a *TxError.
+func (a *bunAdapter) runInTx(ctx context.Context, db bun.IDB, fn func(ctx context.Context, tx bun.Tx) error) error {
+	op, _ := ctx.Value(operationContextKey{}).(*operation)
+	rows := op.rowsAffected()
+	if err := a.retry(ctx, func() error {
+		err := db.RunInTx(ctx, &sql.TxOptions{}, fn)
+		if err != nil {
+			// the rows of an attempt that rolled back are not counted
+			op.resetRowsAffected(rows)
+		}
+		return err
+	}); err != nil {
+		return &TxError{Err: classifyError(err)}
+	}
+	return nil
+}
+
+// retry runs fn until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or ctx is done.
+func (a *bunAdapter) retry(ctx context.Context, fn func() error) error {
+	policy := a.retryPolicy
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		var wait time.Duration
+		if policy.Backoff != nil {
+			wait = policy.Backoff(attempt + 1)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "mysql deadlock", err: &mysql.MySQLError{Number: 1213}, want: true},
+		{name: "mysql duplicate entry", err: &mysql.MySQLError{Number: 1062}, want: false},
+		{name: "postgres serialization failure", err: sqlStateError("40001"), want: true},
+		{name: "postgres deadlock", err: fmt.Errorf("wrapped: %w", sqlStateError("40P01")), want: true},
+		{name: "postgres unique violation", err: sqlStateError("23505"), want: false},
+		{name: "mssql deadlock", err: mssql.Error{Number: 1205}, want: true},
+		{name: "mssql syntax error", err: mssql.Error{Number: 102}, want: false},
+		{name: "other error", err: errors.New("boom"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := backoff(i + 2); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+2, got, w)
+		}
+	}
+}
+
+func TestBunAdapter_runInTx(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213}
+
+	// 1. the whole transaction is re-run until it succeeds
+	a := newSQLiteAdapter(t, openSQLite(t), WithRetryPolicy(RetryPolicy{MaxAttempts: 3})).(*bunAdapter)
+	attempts := 0
+	err := a.runInTx(context.Background(), a.db, func(ctx context.Context, tx bun.Tx) error {
+		attempts++
+		policy := newCasbinPolicy("p", []string{"alice", "data1", "read"})
+		if _, err := tx.NewInsert().Model(&policy).ExcludeColumn(tenantColumn).Exec(ctx); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return deadlock
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runInTx() error = %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("got %d attempts, want 3", attempts)
+	}
+	count, err := a.db.NewSelect().Model((*CasbinPolicy)(nil)).Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("failed attempts must be rolled back, got %d rows", count)
+	}
+
+	// 2. retries stop after MaxAttempts
+	attempts = 0
+	err = a.runInTx(context.Background(), a.db, func(ctx context.Context, tx bun.Tx) error {
+		attempts++
+		return deadlock
+	})
+	if !errors.Is(err, deadlock) || attempts != 3 {
+		t.Errorf("got %v after %d attempts, want the deadlock after 3", err, attempts)
+	}
+
+	// 3. errors that are not retryable are returned at once
+	attempts = 0
+	boom := errors.New("boom")
+	err = a.runInTx(context.Background(), a.db, func(ctx context.Context, tx bun.Tx) error {
+		attempts++
+		return boom
+	})
+	if !errors.Is(err, boom) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want boom after 1", err, attempts)
+	}
+
+	// 4. retries stop when the context is done
+	a.retryPolicy = RetryPolicy{MaxAttempts: 10, Backoff: func(int) time.Duration { return time.Hour }}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	attempts = 0
+	err = a.runInTx(ctx, a.db, func(ctx context.Context, tx bun.Tx) error {
+		attempts++
+		return deadlock
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want the deadline after 1", err, attempts)
+	}
+}
@@ -0,0 +1,194 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/uptrace/bun"
+)
+
+// policyMetaTable is the table that holds the revision of the rules of each policy table, tenant and namespace.
+const policyMetaTable = "casbin_policy_meta"
+
+// anyRevision makes bumpRevision increment the revision whatever it is.
+const anyRevision = -1
+
+// policyMeta is a row of casbin_policy_meta.
+// The rules of a policy table, tenant and namespace are at revision 0 until they are first written.
+// One revision per tenant and namespace is enough because every write of an adapter, including the one without
+// a tenant or a namespace, is confined to the rows of its own tenant and namespace.
+type policyMeta struct {
+	bun.BaseModel `bun:"casbin_policy_meta,alias:cpm"`
+	PolicyTable   string `bun:"policy_table,pk,type:varchar(100)"`
+	TenantID      string `bun:"tenant_id,pk,type:varchar(100)"`
+	Namespace     string `bun:"namespace,pk,type:varchar(100)"`
+	Revision      int64  `bun:"revision,notnull"`
+}
+
+// revisionSet holds the revision that LoadPolicy read for each tenant.
+// It is shared by the tenant-scoped copies of an adapter, which all have the namespace of the adapter.
+type revisionSet struct {
+	mu        sync.Mutex
+	revisions map[string]int64
+}
+
+func newRevisionSet() *revisionSet {
+	return &revisionSet{revisions: make(map[string]int64)}
+}
+
+// get returns the revision loaded for the tenant, if any.
+func (s *revisionSet) get(tenantID string) (int64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revision, ok := s.revisions[tenantID]
+	return revision, ok
+}
+
+// set records the revision loaded for the tenant.
+func (s *revisionSet) set(tenantID string, revision int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revisions[tenantID] = revision
+}
+
+// advance moves the revision of the tenant from one revision to the next,
+// unless another writer changed the rules since the revision was loaded.
+func (s *revisionSet) advance(tenantID string, from, to int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if revision, ok := s.revisions[tenantID]; ok && revision == from {
+		s.revisions[tenantID] = to
+	}
+}
+
+// createMetaTable creates casbin_policy_meta unless it exists.
+func (a *bunAdapter) createMetaTable(ctx context.Context, db bun.IDB) error {
+	_, err := db.NewCreateTable().
+		Model((*policyMeta)(nil)).
+		IfNotExists().
+		Exec(ctx)
+	return err
+}
+
+// metaQuery restricts the query to the row of casbin_policy_meta of the adapter's tenant and namespace.
+func metaQuery[Q whereQuery[Q]](a *bunAdapter, query Q) Q {
+	return query.
+		Where("policy_table = ?", a.modelTable().Name).
+		Where("tenant_id = ?", a.tenantID).
+		Where("namespace = ?", a.namespace)
+}
+
+// readRevision returns the revision of the rules of the adapter's tenant and namespace.
+func (a *bunAdapter) readRevision(ctx context.Context, db bun.IDB) (int64, error) {
+	var revision int64
+	query := db.NewSelect().
+		Model((*policyMeta)(nil)).
+		Column("revision")
+	err := metaQuery(a, query).Scan(ctx, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return revision, err
+}
+
+// bumpRevision increments the revision of the rules of the adapter's tenant and namespace and returns the new revision.
+// Unless expected is anyRevision, the revision is only incremented from expected,
+// and ErrConcurrentModification is returned when it has changed.
+func (a *bunAdapter) bumpRevision(ctx context.Context, db bun.IDB, expected int64) (int64, error) {
+	if expected == 0 {
+		inserted, err := a.insertRevision(ctx, db)
+		if err != nil {
+			return 0, err
+		}
+		if !inserted {
+			return 0, ErrConcurrentModification
+		}
+		return 1, nil
+	}
+
+	query := metaQuery(a, db.NewUpdate().
+		Model((*policyMeta)(nil)).
+		Set("revision = revision + 1"))
+	if expected != anyRevision {
+		query = query.Where("revision = ?", expected)
+	}
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case rows > 0 && expected != anyRevision:
+		return expected + 1, nil
+	case rows > 0:
+		return a.readRevision(ctx, db)
+	case expected != anyRevision:
+		return 0, ErrConcurrentModification
+	}
+
+	// the rules have never been written, unless a concurrent writer just created the row
+	inserted, err := a.insertRevision(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if !inserted {
+		return a.bumpRevision(ctx, db, anyRevision)
+	}
+	return 1, nil
+}
+
+// insertRevision creates the row of the adapter's tenant and namespace at revision 1 and reports whether it did,
+// or whether the row already existed.
+func (a *bunAdapter) insertRevision(ctx context.Context, db bun.IDB) (bool, error) {
+	meta := &policyMeta{PolicyTable: a.modelTable().Name, TenantID: a.tenantID, Namespace: a.namespace, Revision: 1}
+	res, err := db.NewInsert().
+		Model(meta).
+		Ignore().
+		Exec(ctx)
+	if err != nil {
+		// MSSQL has no INSERT IGNORE, and fails on the primary key instead
+		if errors.Is(classifyError(err), ErrDuplicatePolicy) {
+			return false, nil
+		}
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// writeInTx runs fn, which makes an Auto-Save change, in a transaction that also increments the revision.
+// The revision that LoadPolicy read is advanced with it, so that SavePolicy still accepts the model
+// to which the caller made the same change.
+// Like every transaction that writes the rules, it increments the revision before touching the rows,
+// so that the transactions lock the revision and the rows in the same order and cannot deadlock.
+func (a *bunAdapter) writeInTx(ctx context.Context, fn func(ctx context.Context, tx bun.Tx) error) error {
+	var revision int64
+	if err := a.runInTx(ctx, a.db, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		if revision, err = a.bumpRevision(ctx, tx, anyRevision); err != nil {
+			return err
+		}
+		return fn(ctx, tx)
+	}); err != nil {
+		return err
+	}
+	a.revisions.advance(a.tenantID, revision-1, revision)
+	return nil
+}
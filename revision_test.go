@@ -0,0 +1,189 @@
+package casbinbunadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestBunAdapter_ConcurrentModification(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	first := newSQLiteAdapter(t, sqlDB)
+	second := newSQLiteAdapter(t, sqlDB)
+	initPolicy(t, first)
+
+	e1, err := casbin.NewEnforcer("testdata/rbac_model.conf", first)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	e2, err := casbin.NewEnforcer("testdata/rbac_model.conf", second)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+
+	// the Auto-Save of the first enforcer keeps its own model current
+	if _, err := e1.AddPolicy("carol", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e1.SavePolicy(); err != nil {
+		t.Errorf("failed to save policy: %v", err)
+	}
+
+	// the second enforcer loaded the rules before the first one changed them
+	if _, err := e2.AddPolicy("dave", "data4", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e2.SavePolicy(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("got %v, want ErrConcurrentModification", err)
+	}
+	if err := e2.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if err := e2.SavePolicy(); err != nil {
+		t.Errorf("failed to save policy after reloading: %v", err)
+	}
+	if err := e1.SavePolicy(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("got %v, want ErrConcurrentModification", err)
+	}
+
+	b := first.(*bunAdapter)
+	revision, err := b.readRevision(ctx, b.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SavePolicy of initPolicy, AddPolicy, SavePolicy, AddPolicy and SavePolicy
+	if revision != 5 {
+		t.Errorf("got revision %d, want 5", revision)
+	}
+
+	// an import is not in the model of the adapter that made it
+	if err := e1.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if err := first.Import(ctx, bytes.NewBufferString("p, erin, data5, read\n"), FormatCSV, ImportMerge); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if err := e1.SavePolicy(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("got %v, want ErrConcurrentModification", err)
+	}
+
+	// an adapter that never loaded the rules has nothing to compare against
+	third := newSQLiteAdapter(t, sqlDB)
+	if err := third.SavePolicy(e1.GetModel()); err != nil {
+		t.Errorf("failed to save policy: %v", err)
+	}
+}
+
+func TestBunAdapter_ConcurrentModificationTenant(t *testing.T) {
+	sqlDB := openSQLite(t)
+	tenantA := newSQLiteAdapter(t, sqlDB, WithTenant("a"))
+	tenantB := newSQLiteAdapter(t, sqlDB, WithTenant("b"))
+	otherA := newSQLiteAdapter(t, sqlDB, WithTenant("a"))
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", tenantA)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if err := tenantB.AddPolicy("p", "p", []string{"bob", "data2", "write"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.SavePolicy(); err != nil {
+		t.Errorf("got %v, want the rules of another tenant to be independent", err)
+	}
+
+	if err := otherA.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.SavePolicy(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("got %v, want ErrConcurrentModification", err)
+	}
+}
+
+func TestBunAdapter_RevisionFailedSave(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	a := newSQLiteAdapter(t, sqlDB)
+	initPolicy(t, a)
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+
+	// the rows cannot be written, so the revision must not advance either
+	if _, err := sqlDB.ExecContext(ctx, "DROP TABLE casbin_policies"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SavePolicy(); err == nil {
+		t.Fatal("got no error saving into a dropped table")
+	}
+	b := a.(*bunAdapter)
+	revision, err := b.readRevision(ctx, b.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := b.revisions.get(""); revision != 1 || loaded != 1 {
+		t.Errorf("got stored revision %d and loaded revision %d, want 1 after the failed save", revision, loaded)
+	}
+}
+
+func TestBunAdapter_RevisionScopes(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := openSQLite(t)
+	tenantA := newSQLiteAdapter(t, sqlDB, WithTenant("tenant-a"))
+	shared := newSQLiteAdapter(t, sqlDB)
+	initPolicy(t, tenantA)
+	initPolicy(t, shared)
+
+	eTenant, err := casbin.NewEnforcer("testdata/rbac_model.conf", tenantA)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+
+	// the writes of the adapter without a tenant leave the rules of tenant-a and their revision
+	if err := shared.Import(ctx, bytes.NewBufferString("p, erin, data5, read\np, erin, data5, read\n"), FormatCSV, ImportReplace); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if _, err := shared.Deduplicate(ctx); err != nil {
+		t.Fatalf("failed to deduplicate: %v", err)
+	}
+	eShared, err := casbin.NewEnforcer("testdata/rbac_model.conf", shared)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if _, err := eShared.RemoveFilteredPolicy(0, "erin"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+	if err := eShared.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+	if err := eTenant.SavePolicy(); err != nil {
+		t.Errorf("failed to save the policy of tenant-a: %v", err)
+	}
+	if err := eTenant.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(
+		t,
+		eTenant,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+
+	// a write of another adapter of tenant-a conflicts with tenant-a, but not with the adapter without a tenant
+	other := newSQLiteAdapter(t, sqlDB, WithTenant("tenant-a"))
+	if err := other.AddPolicy("p", "p", []string{"carol", "data3", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := eTenant.SavePolicy(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("got %v, want ErrConcurrentModification", err)
+	}
+	if err := eShared.SavePolicy(); err != nil {
+		t.Errorf("failed to save the policy without a tenant: %v", err)
+	}
+}
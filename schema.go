@@ -0,0 +1,239 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// ColumnDiff is a difference between a column of the live policy table and the policy model.
+type ColumnDiff struct {
+	// Column is the name of the column.
+	Column string
+	// Live is the type of the column in the database, empty when the table has no such column.
+	Live string
+	// Want is the type that createTable gives the column.
+	Want string
+	// Safe is true when UpgradeSchema can apply the change without touching the rows:
+	// adding a nullable column or widening a text column.
+	Safe bool
+}
+
+func (d ColumnDiff) String() string {
+	if d.Live == "" {
+		return fmt.Sprintf("%s: missing, want %s", d.Column, d.Want)
+	}
+	return fmt.Sprintf("%s: %s, want %s", d.Column, d.Live, d.Want)
+}
+
+// liveColumn is a column of the policy table as reported by the database.
+type liveColumn struct {
+	Name  string        `bun:"name"`
+	Type  string        `bun:"type"`
+	Width sql.NullInt64 `bun:"width"`
+}
+
+// InspectSchema compares the live policy table with the policy model and the column width of the adapter,
+// and returns the columns that are missing or narrower than the adapter expects.
+// Column widths are not compared on SQLite, which does not enforce them.
+func (a *bunAdapter) InspectSchema(ctx context.Context) (_ []ColumnDiff, err error) {
+	ctx, op := a.startOperation(ctx, "InspectSchema")
+	defer func() { op.end(err) }()
+
+	diffs, err := a.inspectSchema(ctx, a.db)
+	if err != nil {
+		return nil, err
+	}
+	op.addLogAttrs(slog.Int("differences", len(diffs)))
+	return diffs, nil
+}
+
+// inspectSchema implements InspectSchema on db inside the operation of the caller.
+func (a *bunAdapter) inspectSchema(ctx context.Context, db bun.IDB) ([]ColumnDiff, error) {
+	table := a.modelTable()
+	live, err := a.liveColumns(ctx, db, table.Name)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	if len(live) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTableMissing, table.Name)
+	}
+
+	diffs := make([]ColumnDiff, 0)
+	for _, field := range table.Fields {
+		want, width := a.wantColumn(field)
+		column, ok := live[field.Name]
+		if !ok {
+			diffs = append(diffs, ColumnDiff{
+				Column: field.Name,
+				Want:   want,
+				Safe:   !field.NotNull && !field.IsPK,
+			})
+			continue
+		}
+		if width < 0 || a.db.Dialect().Name() == dialect.SQLite {
+			continue
+		}
+		if liveWidth, ok := column.textWidth(); ok && liveWidth > 0 && (width == 0 || liveWidth < width) {
+			diffs = append(diffs, ColumnDiff{
+				Column: field.Name,
+				Live:   column.String(),
+				Want:   want,
+				Safe:   true,
+			})
+		}
+	}
+	return diffs, nil
+}
+
+// UpgradeSchema applies the safe changes that InspectSchema reports, adding the missing nullable columns
+// and widening the narrow text columns, and returns the differences it could not apply.
+// Those need a manual migration, for example with MigrateFrom from a table of another layout.
+func (a *bunAdapter) UpgradeSchema(ctx context.Context) (_ []ColumnDiff, err error) {
+	ctx, op := a.startOperation(ctx, "UpgradeSchema")
+	defer func() { op.end(err) }()
+
+	var unsafe []ColumnDiff
+	applied := make([]string, 0)
+	defer func() {
+		op.addLogAttrs(slog.Any("applied", applied), slog.Int("unsafe", len(unsafe)))
+	}()
+	if err := a.withLock(ctx, func(db bun.IDB) error {
+		diffs, err := a.inspectSchema(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		table := a.modelTable()
+		unsafe = make([]ColumnDiff, 0)
+		for _, diff := range diffs {
+			if !diff.Safe {
+				unsafe = append(unsafe, diff)
+				continue
+			}
+			if err := a.applyColumnDiff(ctx, db, table, diff); err != nil {
+				return err
+			}
+			applied = append(applied, diff.String())
+		}
+
+		// the expiry and scope columns may have been added
+		timeBound, err := a.detectTimeBound(ctx, db)
+		if err != nil {
+			return err
+		}
+		a.timeBound = timeBound
+		liveScopeColumns, err := a.detectScopeColumns(ctx, db)
+		if err != nil {
+			return err
+		}
+		a.liveScopeColumns = liveScopeColumns
+		return nil
+	}); err != nil {
+		return nil, classifyError(err)
+	}
+	return unsafe, nil
+}
+
+func (a *bunAdapter) applyColumnDiff(ctx context.Context, db bun.IDB, table *schema.Table, diff ColumnDiff) error {
+	if diff.Live == "" {
+		_, err := db.NewAddColumn().
+			Model(a.tableModel()).
+			ColumnExpr("? "+diff.Want, bun.Ident(diff.Column)).
+			Exec(ctx)
+		return err
+	}
+
+	want := diff.Want
+	if table.FieldMap[diff.Column].NotNull {
+		want += " NOT NULL"
+	}
+	var query string
+	switch a.db.Dialect().Name() {
+	case dialect.MySQL:
+		query = "ALTER TABLE ? MODIFY ? " + want
+	case dialect.PG:
+		query = "ALTER TABLE ? ALTER COLUMN ? TYPE " + diff.Want
+	default:
+		query = "ALTER TABLE ? ALTER COLUMN ? " + want
+	}
+	_, err := db.NewRaw(query, bun.Ident(table.Name), bun.Ident(diff.Column)).Exec(ctx)
+	return err
+}
+
+// wantColumn returns the type that createTable gives the column of field,
+// with its width, 0 for unlimited text and -1 for the columns that do not hold text.
+func (a *bunAdapter) wantColumn(field *schema.Field) (string, int) {
+	if field.Name == tenantColumn {
+		return a.columnSQLType(tenantColumnWidth), tenantColumnWidth
+	}
+	if field.Name == namespaceColumn {
+		return a.columnSQLType(namespaceColumnWidth), namespaceColumnWidth
+	}
+	for _, column := range ruleColumns {
+		if field.Name == column {
+			return a.columnSQLType(a.columnWidth), max(a.columnWidth, 0)
+		}
+	}
+	return field.CreateTableSQLType, -1
+}
+
+// liveColumns returns the columns of the table by name, read on db, or none if the table does not exist.
+func (a *bunAdapter) liveColumns(ctx context.Context, db bun.IDB, tableName string) (map[string]liveColumn, error) {
+	var query string
+	switch a.db.Dialect().Name() {
+	case dialect.SQLite:
+		query = "SELECT name, type, NULL AS width FROM pragma_table_info(?)"
+	case dialect.MySQL:
+		query = "SELECT column_name AS name, data_type AS type, character_maximum_length AS width " +
+			"FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?"
+	case dialect.PG:
+		query = "SELECT column_name AS name, data_type AS type, character_maximum_length AS width " +
+			"FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?"
+	case dialect.MSSQL:
+		query = "SELECT column_name AS name, data_type AS type, character_maximum_length AS width " +
+			"FROM information_schema.columns WHERE table_schema = SCHEMA_NAME() AND table_name = ?"
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, a.db.Dialect().Name())
+	}
+
+	var columns []liveColumn
+	if err := db.NewRaw(query, tableName).Scan(ctx, &columns); err != nil {
+		return nil, err
+	}
+	live := make(map[string]liveColumn, len(columns))
+	for _, column := range columns {
+		live[strings.ToLower(column.Name)] = column
+	}
+	return live, nil
+}
+
+// textWidth returns the width of a text column, 0 for unlimited, and false for other columns.
+func (c liveColumn) textWidth() (int, bool) {
+	sqlType := strings.ToLower(c.Type)
+	switch {
+	case strings.HasSuffix(sqlType, "text"):
+		return 0, true
+	case strings.Contains(sqlType, "char"):
+		if !c.Width.Valid || c.Width.Int64 < 0 {
+			// character varying without a length on Postgres, and nvarchar(max) on MSSQL
+			return 0, true
+		}
+		return int(c.Width.Int64), true
+	}
+	return 0, false
+}
+
+func (c liveColumn) String() string {
+	if width, ok := c.textWidth(); ok && width > 0 && !strings.Contains(c.Type, "(") {
+		return c.Type + "(" + strconv.Itoa(width) + ")"
+	}
+	return c.Type
+}
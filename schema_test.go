@@ -0,0 +1,204 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBunAdapter_InspectSchema(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. a table created by the adapter
+	a := newSQLiteAdapter(t, openSQLite(t))
+	diffs, err := a.InspectSchema(ctx)
+	if err != nil {
+		t.Fatalf("failed to inspect schema: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("got %v, want no differences", diffs)
+	}
+
+	// 2. an older table without v4, v5, tenant_id and namespace
+	sqlDB := openSQLite(t)
+	if _, err := sqlDB.ExecContext(ctx, `CREATE TABLE casbin_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ptype varchar(100) NOT NULL,
+		v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+	a = newSQLiteAdapter(t, sqlDB)
+	diffs, err = a.InspectSchema(ctx)
+	if err != nil {
+		t.Fatalf("failed to inspect schema: %v", err)
+	}
+	want := []ColumnDiff{
+		{Column: "v4", Want: "varchar(100)", Safe: true},
+		{Column: "v5", Want: "varchar(100)", Safe: true},
+		{Column: "tenant_id", Want: "varchar(100)", Safe: true},
+		{Column: "namespace", Want: "varchar(100)", Safe: true},
+		{Column: "valid_from", Want: "TIMESTAMP", Safe: true},
+		{Column: "expires_at", Want: "TIMESTAMP", Safe: true},
+	}
+	if diff := cmp.Diff(want, diffs); diff != "" {
+		t.Errorf("InspectSchema() mismatch (-want +got):\n%s", diff)
+	}
+
+	unsafe, err := a.UpgradeSchema(ctx)
+	if err != nil {
+		t.Fatalf("failed to upgrade schema: %v", err)
+	}
+	if len(unsafe) != 0 {
+		t.Errorf("got %v, want every difference applied", unsafe)
+	}
+	if diffs, err := a.InspectSchema(ctx); err != nil || len(diffs) != 0 {
+		t.Errorf("got %v, %v after the upgrade, want no differences", diffs, err)
+	}
+	if err := a.AddPolicy("p", "p", []string{"alice", "data1", "read", "a", "b", "c"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	var buf strings.Builder
+	if err := a.Export(ctx, &buf, FormatCSV); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if got, want := strings.TrimSpace(buf.String()), "p, alice, data1, read, a, b, c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// 3. a table whose ptype column has another name cannot be upgraded in place
+	sqlDB = openSQLite(t)
+	if _, err := sqlDB.ExecContext(ctx, `CREATE TABLE casbin_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		p_type varchar(100),
+		v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100), v4 varchar(100), v5 varchar(100))`); err != nil {
+		t.Fatal(err)
+	}
+	a = newSQLiteAdapter(t, sqlDB)
+	unsafe, err = a.UpgradeSchema(ctx)
+	if err != nil {
+		t.Fatalf("failed to upgrade schema: %v", err)
+	}
+	if diff := cmp.Diff([]ColumnDiff{{Column: "ptype", Want: "varchar(100)"}}, unsafe); diff != "" {
+		t.Errorf("UpgradeSchema() mismatch (-want +got):\n%s", diff)
+	}
+
+	// 4. a missing table
+	if _, err := sqlDB.ExecContext(ctx, "DROP TABLE casbin_policies"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.InspectSchema(ctx); !errors.Is(err, ErrTableMissing) {
+		t.Errorf("got %v, want ErrTableMissing", err)
+	}
+}
+
+func TestLiveColumn_textWidth(t *testing.T) {
+	tests := []struct {
+		column    liveColumn
+		wantWidth int
+		wantText  bool
+	}{
+		{column: liveColumn{Type: "varchar", Width: sql.NullInt64{Int64: 100, Valid: true}}, wantWidth: 100, wantText: true},
+		{column: liveColumn{Type: "character varying", Width: sql.NullInt64{Int64: 255, Valid: true}}, wantWidth: 255, wantText: true},
+		{column: liveColumn{Type: "character varying"}, wantWidth: 0, wantText: true},
+		{column: liveColumn{Type: "nvarchar", Width: sql.NullInt64{Int64: -1, Valid: true}}, wantWidth: 0, wantText: true},
+		{column: liveColumn{Type: "longtext", Width: sql.NullInt64{Int64: 4294967295, Valid: true}}, wantWidth: 0, wantText: true},
+		{column: liveColumn{Type: "bigint"}, wantWidth: 0, wantText: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column.Type, func(t *testing.T) {
+			width, ok := tt.column.textWidth()
+			if width != tt.wantWidth || ok != tt.wantText {
+				t.Errorf("textWidth() = %d, %v, want %d, %v", width, ok, tt.wantWidth, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestBunAdapter_MigrateFrom(t *testing.T) {
+	ctx := context.Background()
+
+	for _, layout := range []TableLayout{GormAdapterLayout, XormAdapterLayout} {
+		t.Run(layout.PType, func(t *testing.T) {
+			a := newSQLiteAdapter(t, openSQLite(t))
+			db := a.(*bunAdapter).db
+			if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE casbin_rule (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				%s varchar(100),
+				v0 varchar(100), v1 varchar(100), v2 varchar(100), v3 varchar(100), v4 varchar(100), v5 varchar(100))`, layout.PType)); err != nil {
+				t.Fatal(err)
+			}
+			insert := func(values string) {
+				t.Helper()
+				if _, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO casbin_rule (%s, v0, v1, v2) VALUES %s", layout.PType, values)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			insert(`('p', 'alice', 'data1', 'read'), ('p', 'bob', 'data2', 'write'), ('g', 'alice', 'data2_admin', NULL)`)
+
+			// 1. the rules are copied and verified
+			report, err := a.MigrateFrom(ctx, layout, MigrateOptions{Verify: true})
+			if err != nil {
+				t.Fatalf("failed to migrate: %v", err)
+			}
+			if diff := cmp.Diff(MigrateReport{Read: 3, Copied: 3, Verified: true}, report); diff != "" {
+				t.Errorf("MigrateFrom() mismatch (-want +got):\n%s", diff)
+			}
+			e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+			if err != nil {
+				t.Fatalf("failed to create enforcer: %v", err)
+			}
+			testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+			if got, _ := e.GetGroupingPolicy(); !cmp.Equal(got, [][]string{{"alice", "data2_admin"}}) {
+				t.Errorf("got grouping policy %v, want [[alice data2_admin]]", got)
+			}
+
+			// 2. running again copies only the new rows, and a dry run copies nothing
+			insert(`('p', 'carol', 'data3', 'read'), ('p', 'carol', 'data3', 'read')`)
+			report, err = a.MigrateFrom(ctx, layout, MigrateOptions{DryRun: true, Verify: true})
+			if err != nil {
+				t.Fatalf("failed to migrate: %v", err)
+			}
+			if diff := cmp.Diff(MigrateReport{Read: 5, Copied: 1, Skipped: 4, Verified: true}, report); diff != "" {
+				t.Errorf("MigrateFrom() mismatch (-want +got):\n%s", diff)
+			}
+			if err := e.LoadPolicy(); err != nil {
+				t.Fatalf("failed to load policy: %v", err)
+			}
+			testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}})
+
+			report, err = a.MigrateFrom(ctx, layout, MigrateOptions{})
+			if err != nil {
+				t.Fatalf("failed to migrate: %v", err)
+			}
+			if diff := cmp.Diff(MigrateReport{Read: 5, Copied: 1, Skipped: 4}, report); diff != "" {
+				t.Errorf("MigrateFrom() mismatch (-want +got):\n%s", diff)
+			}
+
+			// 3. the verification fails when the policy table holds other rules
+			if err := a.AddPolicy("p", "p", []string{"dave", "data4", "read"}); err != nil {
+				t.Fatalf("failed to add policy: %v", err)
+			}
+			insert(`('p', 'erin', 'data5', 'read')`)
+			if _, err := a.MigrateFrom(ctx, layout, MigrateOptions{Verify: true}); !errors.Is(err, ErrMigrationMismatch) {
+				t.Errorf("got %v, want ErrMigrationMismatch", err)
+			}
+			if err := e.LoadPolicy(); err != nil {
+				t.Fatalf("failed to load policy: %v", err)
+			}
+			testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"carol", "data3", "read"}, {"dave", "data4", "read"}})
+		})
+	}
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	layout := GormAdapterLayout
+	layout.Values = append(layout.Values, "v6")
+	if _, err := a.MigrateFrom(ctx, layout, MigrateOptions{}); !errors.Is(err, ErrTooManyFields) {
+		t.Errorf("got %v, want ErrTooManyFields", err)
+	}
+}
@@ -0,0 +1,254 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"weak"
+
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName is the name of the tracer and the meter of the adapter.
+const instrumentationName = "github.com/devoteclick/casbin-bun-adapter"
+
+// The attributes of the spans and metrics of the adapter.
+var (
+	operationKey    = attribute.Key("casbin.operation")
+	ptypeKey        = attribute.Key("casbin.ptype")
+	ruleCountKey    = attribute.Key("casbin.rule_count")
+	rowsAffectedKey = attribute.Key("casbin.rows_affected")
+	dialectKey      = attribute.Key("db.system")
+)
+
+// WithTracerProvider makes the adapter create a span for each of its methods with the tracer of provider.
+// The span is in the context that the adapter passes to bun, so the spans of a query hook
+// like bunotel become its children.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(a *bunAdapter) {
+		a.telemetry = a.telemetry.withDefaults()
+		a.telemetry.tracer = provider.Tracer(instrumentationName)
+	}
+}
+
+// WithMeterProvider makes the adapter record the duration of its methods in the casbin.adapter.duration histogram
+// and their errors in the casbin.adapter.errors counter, with the meter of provider.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(a *bunAdapter) {
+		a.telemetry = a.telemetry.withDefaults()
+		meter := provider.Meter(instrumentationName)
+
+		var err error
+		a.telemetry.duration, err = meter.Float64Histogram("casbin.adapter.duration",
+			metric.WithDescription("Duration of the adapter methods."),
+			metric.WithUnit("s"))
+		if err != nil {
+			otel.Handle(err)
+		}
+		a.telemetry.errors, err = meter.Int64Counter("casbin.adapter.errors",
+			metric.WithDescription("Number of the adapter methods that failed."),
+			metric.WithUnit("{error}"))
+		if err != nil {
+			otel.Handle(err)
+		}
+	}
+}
+
+// telemetry holds the tracer and the instruments of an adapter.
+// An adapter without WithTracerProvider and WithMeterProvider has none and records nothing.
+type telemetry struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+// noopTelemetry records nothing, for the operations of an adapter that only logs them.
+var noopTelemetry = (*telemetry)(nil).withDefaults()
+
+// withDefaults returns t, or telemetry that records nothing when t is nil.
+func (t *telemetry) withDefaults() *telemetry {
+	if t != nil {
+		return t
+	}
+	meter := metricnoop.NewMeterProvider().Meter(instrumentationName)
+	duration, _ := meter.Float64Histogram("casbin.adapter.duration")
+	errors, _ := meter.Int64Counter("casbin.adapter.errors")
+	return &telemetry{
+		tracer:   tracenoop.NewTracerProvider().Tracer(instrumentationName),
+		duration: duration,
+		errors:   errors,
+	}
+}
+
+type operationContextKey struct{}
+
+// operation is an adapter method being recorded.
+type operation struct {
+	ctx       context.Context
+	telemetry *telemetry
+	span      trace.Span
+	start     time.Time
+	metrics   metric.MeasurementOption
+	rows      atomic.Int64
+
+	name          string
+	dialect       string
+	ptype         string
+	ruleCount     int
+	logger        *slog.Logger
+	logRuleValues bool
+	logAttrs      []slog.Attr
+}
+
+// startOperation starts recording the adapter method name and returns the context to run it with.
+// The returned operation is nil when the adapter has neither telemetry nor a logger, and its methods then do nothing.
+func (a *bunAdapter) startOperation(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	if a.telemetry == nil && a.logger == nil {
+		return ctx, nil
+	}
+
+	t := a.telemetry
+	if t == nil {
+		t = noopTelemetry
+	}
+	dialect := a.db.Dialect().Name().String()
+	common := []attribute.KeyValue{
+		operationKey.String(name),
+		dialectKey.String(dialect),
+	}
+	ctx, span := t.tracer.Start(ctx, "casbin."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(common...),
+		trace.WithAttributes(attrs...))
+	op := &operation{
+		telemetry:     t,
+		span:          span,
+		start:         time.Now(),
+		metrics:       metric.WithAttributes(common...),
+		name:          name,
+		dialect:       dialect,
+		ruleCount:     -1,
+		logger:        a.logger,
+		logRuleValues: a.logRuleValues,
+	}
+	for _, kv := range attrs {
+		switch kv.Key {
+		case ptypeKey:
+			op.ptype = kv.Value.AsString()
+		case ruleCountKey:
+			op.ruleCount = int(kv.Value.AsInt64())
+		}
+	}
+	op.ctx = context.WithValue(ctx, operationContextKey{}, op)
+	return op.ctx, op
+}
+
+// setRuleCount records the number of rules the method read, for the methods whose input does not tell it.
+func (o *operation) setRuleCount(count int) {
+	if o == nil {
+		return
+	}
+	o.ruleCount = count
+	o.span.SetAttributes(ruleCountKey.Int(count))
+}
+
+// rowsAffected returns the rows affected by the queries of the method so far.
+func (o *operation) rowsAffected() int64 {
+	if o == nil {
+		return 0
+	}
+	return o.rows.Load()
+}
+
+// resetRowsAffected sets the rows affected back to a count read by rowsAffected,
+// dropping the rows of a transaction that rolled back.
+func (o *operation) resetRowsAffected(rows int64) {
+	if o == nil {
+		return
+	}
+	o.rows.Store(rows)
+}
+
+// end records the result of the method.
+func (o *operation) end(err error) {
+	if o == nil {
+		return
+	}
+
+	o.span.SetAttributes(rowsAffectedKey.Int64(o.rows.Load()))
+	if err != nil {
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+		o.telemetry.errors.Add(o.ctx, 1, o.metrics)
+	}
+	duration := time.Since(o.start)
+	o.telemetry.duration.Record(o.ctx, duration.Seconds(), o.metrics)
+	o.log(duration, err)
+	o.span.End()
+}
+
+// rowsHook adds the rows affected by the queries of an operation to the operation.
+type rowsHook struct{}
+
+var (
+	// rowsHookDBs holds the databases that have a rowsHook, which the adapters that share a database share.
+	// The databases are held weakly, and dropped from the map once they are garbage collected.
+	rowsHookDBs   = make(map[weak.Pointer[bun.DB]]struct{})
+	rowsHookDBsMu sync.Mutex
+)
+
+// addRowsHook adds a rowsHook to db unless it has one, so that the rows of a query are counted once
+// however many adapters use db.
+func addRowsHook(db *bun.DB) {
+	rowsHookDBsMu.Lock()
+	defer rowsHookDBsMu.Unlock()
+	key := weak.Make(db)
+	if _, ok := rowsHookDBs[key]; ok {
+		return
+	}
+	rowsHookDBs[key] = struct{}{}
+	runtime.AddCleanup(db, func(key weak.Pointer[bun.DB]) {
+		rowsHookDBsMu.Lock()
+		defer rowsHookDBsMu.Unlock()
+		delete(rowsHookDBs, key)
+	}, key)
+	db.AddQueryHook(rowsHook{})
+}
+
+var _ bun.QueryHook = rowsHook{}
+
+func (rowsHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (rowsHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	op, ok := ctx.Value(operationContextKey{}).(*operation)
+	if !ok || event.Err != nil || event.Result == nil {
+		return
+	}
+	// the drivers report the rows of the last write for other statements, like the ALTER TABLE of UpgradeSchema
+	switch event.Operation() {
+	case "INSERT", "UPDATE", "DELETE", "MERGE":
+	default:
+		return
+	}
+	// the revision and the lock are not part of the rows affected by the method
+	if event.IQuery != nil {
+		if table := event.IQuery.GetTableName(); table == policyMetaTable || table == lockTable {
+			return
+		}
+	}
+	if rows, err := event.Result.RowsAffected(); err == nil {
+		op.rows.Add(rows)
+	}
+}
@@ -0,0 +1,115 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestBunAdapter_Telemetry(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	a := newSQLiteAdapter(t, openSQLite(t), WithTracerProvider(tracerProvider), WithMeterProvider(meterProvider))
+	initPolicy(t, a)
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	exporter.Reset()
+
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if _, err := e.RemoveFilteredPolicy(0, "data2_admin"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"a", "b", "c", "d", "e", "f", "g"}}); err == nil {
+		t.Fatal("got no error for a rule with too many fields")
+	}
+
+	spans := exporter.GetSpans().Snapshots()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	tests := []struct {
+		name         string
+		ptype        string
+		ruleCount    int64
+		rowsAffected int64
+		failed       bool
+	}{
+		{name: "casbin.LoadPolicy", ruleCount: 5},
+		{name: "casbin.RemoveFilteredPolicy", ptype: "p", rowsAffected: 2},
+		{name: "casbin.AddPolicies", ptype: "p", ruleCount: 2, failed: true},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name {
+			t.Errorf("got span %s, want %s", span.Name(), tt.name)
+			continue
+		}
+		if v, _ := spanAttribute(span, dialectKey); v.AsString() != "sqlite" {
+			t.Errorf("%s: got db.system %q, want sqlite", tt.name, v.AsString())
+		}
+		if v, _ := spanAttribute(span, ptypeKey); v.AsString() != tt.ptype {
+			t.Errorf("%s: got ptype %q, want %q", tt.name, v.AsString(), tt.ptype)
+		}
+		if v, _ := spanAttribute(span, ruleCountKey); v.AsInt64() != tt.ruleCount {
+			t.Errorf("%s: got rule count %d, want %d", tt.name, v.AsInt64(), tt.ruleCount)
+		}
+		if v, _ := spanAttribute(span, rowsAffectedKey); v.AsInt64() != tt.rowsAffected {
+			t.Errorf("%s: got rows affected %d, want %d", tt.name, v.AsInt64(), tt.rowsAffected)
+		}
+		if failed := span.Status().Code == codes.Error; failed != tt.failed {
+			t.Errorf("%s: got failed %v, want %v", tt.name, failed, tt.failed)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+	durations, errorCount := map[string]uint64{}, map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value(operationKey)
+					durations[op.AsString()] += dp.Count
+				}
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value(operationKey)
+					errorCount[op.AsString()] += dp.Value
+				}
+			}
+		}
+	}
+	if durations["LoadPolicy"] != 3 || durations["RemoveFilteredPolicy"] != 1 || durations["AddPolicies"] != 1 {
+		t.Errorf("got durations recorded %v, want 3 LoadPolicy, 1 RemoveFilteredPolicy and 1 AddPolicies", durations)
+	}
+	if len(errorCount) != 1 || errorCount["AddPolicies"] != 1 {
+		t.Errorf("got errors %v, want 1 AddPolicies", errorCount)
+	}
+}
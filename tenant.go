@@ -0,0 +1,149 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// tenantColumn is the column that holds the tenant of a policy rule.
+const tenantColumn = "tenant_id"
+
+type tenantContextKey struct{}
+
+// WithTenant scopes the adapter to a single tenant.
+// Every query the adapter issues is restricted to the rows of that tenant,
+// and SavePolicy replaces only that tenant's rows instead of every row of the table.
+// An adapter without a tenant reads and writes the rows that have no tenant,
+// so that it can share the table with the tenants' adapters.
+// The tenant must fit in the 100 characters of the tenant_id column, or the constructors fail with a *RuleTooLongError.
+func WithTenant(tenantID string) Option {
+	return func(a *bunAdapter) {
+		a.tenantID = tenantID
+	}
+}
+
+// ContextWithTenant returns a copy of ctx that carries the tenant.
+// The context adapter scopes each call to the tenant found in its context,
+// overriding the tenant given by WithTenant.
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant stored in ctx by ContextWithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok
+}
+
+// withTenant returns a shallow copy of the adapter that is scoped to the tenant.
+func (a *bunAdapter) withTenant(tenantID string) *bunAdapter {
+	b := *a
+	b.tenantID = tenantID
+	return &b
+}
+
+// whereQuery is implemented by bun's select, update and delete queries.
+type whereQuery[Q any] interface {
+	Where(query string, args ...interface{}) Q
+}
+
+// columnQuery is implemented by bun's select, insert and update queries.
+type columnQuery[Q any] interface {
+	ExcludeColumn(columns ...string) Q
+}
+
+// scopeColumns are the columns that scope the rows of the table to the adapter.
+var scopeColumns = []string{tenantColumn, namespaceColumn}
+
+// scopeQuery restricts the query to the rows of the adapter's tenant and namespace.
+func scopeQuery[Q whereQuery[Q]](a *bunAdapter, query Q) Q {
+	conditions, args := a.scopeConditions()
+	if len(conditions) == 0 {
+		return query
+	}
+	return query.Where(strings.Join(conditions, " AND "), args...)
+}
+
+// scopeConditions returns the conditions that match the rows of the adapter's tenant and namespace, and their arguments.
+// An adapter without a tenant or a namespace matches the rows whose tenant_id or namespace is NULL,
+// unless the table has no such column.
+func (a *bunAdapter) scopeConditions() ([]string, []interface{}) {
+	// args stays nil without placeholders, which bun would warn about
+	var conditions []string
+	var args []interface{}
+	if a.tenantID != "" {
+		conditions = append(conditions, "tenant_id = ?")
+		args = append(args, a.tenantID)
+	} else if a.liveScopeColumns[tenantColumn] {
+		conditions = append(conditions, "tenant_id IS NULL")
+	}
+	if a.namespace != "" {
+		conditions = append(conditions, "namespace = ?")
+		args = append(args, a.namespace)
+	} else if a.liveScopeColumns[namespaceColumn] {
+		conditions = append(conditions, "namespace IS NULL")
+	}
+	return conditions, args
+}
+
+// detectScopeColumns returns which of the scope columns both the policy model and the live table have.
+func (a *bunAdapter) detectScopeColumns(ctx context.Context, db bun.IDB) (map[string]bool, error) {
+	live, err := a.liveColumns(ctx, db, a.modelTable().Name)
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]bool, len(scopeColumns))
+	for _, column := range scopeColumns {
+		_, ok := live[column]
+		columns[column] = ok && a.hasColumn(column)
+	}
+	return columns, nil
+}
+
+// excludeColumns drops the optional columns that the adapter does not use,
+// so that tables created before those columns existed keep working.
+func excludeColumns[Q columnQuery[Q]](a *bunAdapter, query Q) Q {
+	columns := make([]string, 0, 2+len(expiryColumns))
+	if a.tenantID == "" && a.hasColumn(tenantColumn) {
+		columns = append(columns, tenantColumn)
+	}
+	if a.namespace == "" && a.hasColumn(namespaceColumn) {
+		columns = append(columns, namespaceColumn)
+	}
+	if !a.timeBound {
+		for _, column := range expiryColumns {
+			if a.hasColumn(column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return query
+	}
+	return query.ExcludeColumn(columns...)
+}
+
+// newPolicy creates a CasbinPolicy that belongs to the adapter's tenant and namespace.
+func (a *bunAdapter) newPolicy(ptype string, rule []string) (CasbinPolicy, error) {
+	if len(rule) > maxRuleLength {
+		return CasbinPolicy{}, fmt.Errorf("%w: %d values in %v, at most %d are stored", ErrTooManyFields, len(rule), rule, maxRuleLength)
+	}
+	policy := newCasbinPolicy(ptype, rule)
+	policy.TenantID, policy.Namespace = a.tenantID, a.namespace
+	return policy, nil
+}
+
+func (a *bunAdapter) newPolicies(ptype string, rules [][]string) ([]CasbinPolicy, error) {
+	policies := make([]CasbinPolicy, 0, len(rules))
+	for _, rule := range rules {
+		policy, err := a.newPolicy(ptype, rule)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
@@ -0,0 +1,134 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestBunAdapter_Tenant(t *testing.T) {
+	sqlDB := openSQLite(t)
+	tenantA := newSQLiteAdapter(t, sqlDB, WithTenant("tenant-a"))
+	tenantB := newSQLiteAdapter(t, sqlDB, WithTenant("tenant-b"))
+
+	// 1. SavePolicy of one tenant does not touch the other tenant
+	initPolicy(t, tenantA)
+	initPolicy(t, tenantB)
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", tenantB)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	e.ClearPolicy()
+	if err := e.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+
+	e, err = casbin.NewEnforcer("testdata/rbac_model.conf", tenantA)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+
+	// 2. Auto-Save operations are scoped to the tenant
+	initPolicy(t, tenantB)
+	if _, err := e.RemoveFilteredPolicy(0, "data2_admin"); err != nil {
+		t.Fatalf("failed to remove filtered policy: %v", err)
+	}
+	if _, err := e.UpdatePolicy([]string{"alice", "data1", "read"}, []string{"alice", "data1", "write"}); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if _, err := e.AddPolicy("carol", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "write"}, {"bob", "data2", "write"}, {"carol", "data3", "read"}},
+	)
+
+	e, err = casbin.NewEnforcer("testdata/rbac_model.conf", tenantB)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+}
+
+func TestCtxBunAdapter_Tenant(t *testing.T) {
+	sqlDB := openSQLite(t)
+	a := newSQLiteAdapter(t, sqlDB, WithTenant("tenant-a"))
+	initPolicy(t, a)
+	ca := &ctxBunAdapter{Adapter: a}
+
+	ctx := ContextWithTenant(context.Background(), "tenant-b")
+	if err := ca.AddPolicyCtx(ctx, "p", "p", []string{"bob", "data1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf")
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	if err := ca.LoadPolicyCtx(ctx, e.GetModel()); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"bob", "data1", "read"}})
+
+	e.ClearPolicy()
+	if err := ca.LoadPolicyCtx(context.Background(), e.GetModel()); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+}
+
+func TestBunAdapter_TenantShared(t *testing.T) {
+	sqlDB := openSQLite(t)
+	tenantA := newSQLiteAdapter(t, sqlDB, WithTenant("tenant-a"))
+	shared := newSQLiteAdapter(t, sqlDB)
+	initPolicy(t, tenantA)
+
+	// the adapter without a tenant does not see the rules of tenant-a
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", shared)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{})
+
+	// and its SavePolicy replaces only the rules without a tenant
+	if _, err := e.AddPolicy("carol", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := e.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+	e.ClearPolicy()
+	if err := e.SavePolicy(); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+
+	e, err = casbin.NewEnforcer("testdata/rbac_model.conf", tenantA)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+	)
+}
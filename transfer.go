@@ -0,0 +1,428 @@
+package casbinbunadapter
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the serialization format used by Export and Import.
+type Format string
+
+const (
+	// FormatCSV is the format of the Casbin policy file, e.g. testdata/rbac_policy.csv.
+	// It has no room for the expiry of a rule, so Export writes only the rules in effect.
+	FormatCSV Format = "csv"
+	// FormatJSON is a JSON array of {"ptype": "p", "rule": ["alice", "data1", "read"]} objects,
+	// with the valid_from and expires_at of a time-bound rule as RFC 3339 timestamps.
+	FormatJSON Format = "json"
+	// FormatYAML is a stream of YAML documents, each holding one rule with ptype and rule keys,
+	// and the valid_from and expires_at keys of a time-bound rule.
+	// A single document holding a sequence of rules is accepted by Import as well.
+	FormatYAML Format = "yaml"
+)
+
+// ImportMode decides what happens to the stored rules when rules are imported.
+type ImportMode string
+
+const (
+	// ImportReplace deletes the stored rules before the imported rules are inserted.
+	ImportReplace ImportMode = "replace"
+	// ImportMerge keeps the stored rules and inserts only the imported rules that are not stored yet.
+	ImportMerge ImportMode = "merge"
+)
+
+// importBatchSize is the number of rules inserted by one statement during Import.
+const importBatchSize = 1000
+
+// policyRecord is the shape of a rule in the JSON and YAML formats.
+type policyRecord struct {
+	PType     string     `json:"ptype" yaml:"ptype"`
+	Rule      []string   `json:"rule" yaml:"rule,flow"`
+	ValidFrom *time.Time `json:"valid_from,omitempty" yaml:"valid_from,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+// newPolicyRecord returns the record of the policy, with its expiry if withExpiry is set.
+func newPolicyRecord(policy CasbinPolicy, withExpiry bool) policyRecord {
+	record := policyRecord{PType: policy.PType, Rule: policy.ruleValues()}
+	if !withExpiry {
+		return record
+	}
+	if !policy.ValidFrom.IsZero() {
+		record.ValidFrom = &policy.ValidFrom.Time
+	}
+	if !policy.ExpiresAt.IsZero() {
+		record.ExpiresAt = &policy.ExpiresAt.Time
+	}
+	return record
+}
+
+type policyEncoder interface {
+	encode(record policyRecord) error
+	close() error
+}
+
+// policyDecoder returns io.EOF once all records are read.
+type policyDecoder interface {
+	decode() (policyRecord, error)
+}
+
+// Export writes the policy rules in the storage to w in the given format, leaving out the expired rules.
+// JSON and YAML keep the expiry of the time-bound rules, including the rules not in effect yet,
+// while CSV has no room for it and holds only the rules in effect, as LoadPolicy would load them.
+// Rows are streamed from the database in id order, so the whole table is never held in memory.
+func (a *bunAdapter) Export(ctx context.Context, w io.Writer, format Format) (err error) {
+	ctx, op := a.startOperation(ctx, "Export")
+	defer func() { op.end(err) }()
+
+	encoder, err := newPolicyEncoder(w, format)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	withExpiry := format != FormatCSV
+	query := a.selectPolicies(a.readDB()).
+		Order("id")
+	switch {
+	case !withExpiry:
+		query = activeQuery(a, query, now)
+	case a.timeBound:
+		query = query.Where("expires_at IS NULL OR expires_at > ?", now)
+	}
+	count := 0
+	if err := a.forEachPolicy(ctx, query, func(policy CasbinPolicy) error {
+		count++
+		return encoder.encode(newPolicyRecord(policy, withExpiry))
+	}); err != nil {
+		return classifyError(err)
+	}
+	op.setRuleCount(count)
+
+	return encoder.close()
+}
+
+// Import reads policy rules in the given format from r and stores them in one transaction.
+// The expiry of a rule is stored as AddPolicyWithExpiry stores it.
+// ImportReplace takes the lock of the policy table, like SavePolicy.
+// Rules are inserted in batches while r is read, so the input is never held in memory.
+func (a *bunAdapter) Import(ctx context.Context, r io.Reader, format Format, mode ImportMode) (err error) {
+	ctx, op := a.startOperation(ctx, "Import")
+	defer func() { op.end(err) }()
+
+	if mode != ImportReplace && mode != ImportMerge {
+		return fmt.Errorf("unsupported import mode: %s", mode)
+	}
+	decoder, err := newPolicyDecoder(r, format)
+	if err != nil {
+		return err
+	}
+
+	if mode == ImportReplace {
+		// a replace deletes the rows as SavePolicy does, so it takes the same lock
+		return classifyError(a.withLock(ctx, func(db bun.IDB) error {
+			return a.importPolicies(ctx, db, decoder, mode)
+		}))
+	}
+	return a.importPolicies(ctx, a.db, decoder, mode)
+}
+
+// importPolicies stores the rules read by decoder on db in one transaction.
+func (a *bunAdapter) importPolicies(ctx context.Context, db bun.IDB, decoder policyDecoder, mode ImportMode) error {
+	return a.runInTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+		// the imported rules are not in the model of any enforcer, so its SavePolicy must fail
+		if _, err := a.bumpRevision(ctx, tx, anyRevision); err != nil {
+			return err
+		}
+		if mode == ImportReplace {
+			query := tx.NewDelete().
+				Model(a.tableModel())
+			if !a.scoped() {
+				query = query.Where("1 = 1")
+			}
+			if _, err := scopeQuery(a, query).Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		batch := make([]CasbinPolicy, 0, importBatchSize)
+		pending := make(map[CasbinPolicy]struct{})
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := a.insertPolicies(ctx, tx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+			clear(pending)
+			return nil
+		}
+
+		for {
+			record, err := decoder.decode()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if record.PType == "" {
+				return errors.New("policy rule without ptype")
+			}
+
+			policy, err := a.newPolicy(record.PType, record.Rule)
+			if err != nil {
+				return err
+			}
+			if record.ValidFrom != nil || record.ExpiresAt != nil {
+				if !a.timeBound {
+					return ErrExpiryUnsupported
+				}
+				if record.ValidFrom != nil {
+					policy.ValidFrom = bun.NullTime{Time: *record.ValidFrom}
+				}
+				if record.ExpiresAt != nil {
+					policy.ExpiresAt = bun.NullTime{Time: *record.ExpiresAt}
+				}
+			}
+			if err := a.checkWidth(policy); err != nil {
+				return err
+			}
+			if mode == ImportMerge {
+				if _, ok := pending[policy]; ok {
+					continue
+				}
+				exists, err := a.existsInTx(ctx, tx, policy)
+				if err != nil {
+					return err
+				}
+				if exists {
+					continue
+				}
+				pending[policy] = struct{}{}
+			}
+
+			batch = append(batch, policy)
+			if len(batch) == importBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return flush()
+	})
+}
+
+// existsInTx reports whether a row with exactly the same values as the policy is stored.
+func (a *bunAdapter) existsInTx(ctx context.Context, tx bun.Tx, policy CasbinPolicy) (bool, error) {
+	query := tx.NewSelect().
+		Model(a.tableModel()).
+		Where(a.equalClause("ptype"), policy.PType).
+		Where(a.equalClause("v0"), policy.V0).
+		Where(a.equalClause("v1"), policy.V1).
+		Where(a.equalClause("v2"), policy.V2).
+		Where(a.equalClause("v3"), policy.V3).
+		Where(a.equalClause("v4"), policy.V4).
+		Where(a.equalClause("v5"), policy.V5)
+	return scopeQuery(a, query).Exists(ctx)
+}
+
+func newPolicyEncoder(w io.Writer, format Format) (policyEncoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvPolicyEncoder{w: bufio.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonPolicyEncoder{w: bufio.NewWriter(w)}, nil
+	case FormatYAML:
+		return &yamlPolicyEncoder{encoder: yaml.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func newPolicyDecoder(r io.Reader, format Format) (policyDecoder, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.Comment = '#'
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return &csvPolicyDecoder{reader: reader}, nil
+	case FormatJSON:
+		return &jsonPolicyDecoder{decoder: json.NewDecoder(r)}, nil
+	case FormatYAML:
+		return &yamlPolicyDecoder{decoder: yaml.NewDecoder(r)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// csvPolicyEncoder writes rules the way the Casbin policy file does, e.g. "p, alice, data1, read".
+type csvPolicyEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *csvPolicyEncoder) encode(record policyRecord) error {
+	fields := append([]string{record.PType}, record.Rule...)
+	for i, field := range fields {
+		if i > 0 {
+			if _, err := e.w.WriteString(", "); err != nil {
+				return err
+			}
+		}
+		if _, err := e.w.WriteString(quoteCSVField(field)); err != nil {
+			return err
+		}
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *csvPolicyEncoder) close() error {
+	return e.w.Flush()
+}
+
+// quoteCSVField quotes the field if it would not be read back as is.
+func quoteCSVField(field string) string {
+	if field == "" || (!strings.ContainsAny(field, ",\"\r\n#") && strings.TrimSpace(field) == field) {
+		return field
+	}
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+type csvPolicyDecoder struct {
+	reader *csv.Reader
+}
+
+func (d *csvPolicyDecoder) decode() (policyRecord, error) {
+	fields, err := d.reader.Read()
+	if err != nil {
+		return policyRecord{}, err
+	}
+	return policyRecord{PType: fields[0], Rule: fields[1:]}, nil
+}
+
+// jsonPolicyEncoder writes a JSON array one element at a time.
+type jsonPolicyEncoder struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (e *jsonPolicyEncoder) encode(record policyRecord) error {
+	prefix := ",\n  "
+	if e.count == 0 {
+		prefix = "[\n  "
+	}
+	e.count++
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.WriteString(prefix); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(b); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *jsonPolicyEncoder) close() error {
+	suffix := "\n]\n"
+	if e.count == 0 {
+		suffix = "[]\n"
+	}
+	if _, err := e.w.WriteString(suffix); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// jsonPolicyDecoder reads a JSON array one element at a time.
+type jsonPolicyDecoder struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func (d *jsonPolicyDecoder) decode() (policyRecord, error) {
+	if !d.started {
+		token, err := d.decoder.Token()
+		if err != nil {
+			return policyRecord{}, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return policyRecord{}, fmt.Errorf("expected a JSON array of policy rules, got %v", token)
+		}
+		d.started = true
+	}
+
+	if !d.decoder.More() {
+		if _, err := d.decoder.Token(); err != nil {
+			return policyRecord{}, err
+		}
+		return policyRecord{}, io.EOF
+	}
+
+	var record policyRecord
+	if err := d.decoder.Decode(&record); err != nil {
+		return policyRecord{}, err
+	}
+	return record, nil
+}
+
+type yamlPolicyEncoder struct {
+	encoder *yaml.Encoder
+}
+
+func (e *yamlPolicyEncoder) encode(record policyRecord) error {
+	return e.encoder.Encode(record)
+}
+
+func (e *yamlPolicyEncoder) close() error {
+	return e.encoder.Close()
+}
+
+// yamlPolicyDecoder reads one document at a time.
+// A document is either a single rule or a sequence of rules.
+type yamlPolicyDecoder struct {
+	decoder *yaml.Decoder
+	queue   []*yaml.Node
+}
+
+func (d *yamlPolicyDecoder) decode() (policyRecord, error) {
+	for len(d.queue) == 0 {
+		var node yaml.Node
+		if err := d.decoder.Decode(&node); err != nil {
+			return policyRecord{}, err
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+		document := node.Content[0]
+		if document.Kind == yaml.SequenceNode {
+			d.queue = document.Content
+		} else {
+			d.queue = []*yaml.Node{document}
+		}
+	}
+
+	var record policyRecord
+	node := d.queue[0]
+	d.queue = d.queue[1:]
+	if err := node.Decode(&record); err != nil {
+		return policyRecord{}, err
+	}
+	return record, nil
+}
@@ -0,0 +1,184 @@
+package casbinbunadapter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/uptrace/bun"
+)
+
+func TestBunAdapter_ExportCSV(t *testing.T) {
+	a := newSQLiteAdapter(t, openSQLite(t))
+	initPolicy(t, a)
+
+	var buf bytes.Buffer
+	if err := a.Export(context.Background(), &buf, FormatCSV); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	want, err := os.ReadFile("testdata/rbac_policy.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.TrimSpace(string(want)) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBunAdapter_ExportImport(t *testing.T) {
+	rules := [][]string{
+		{"alice", "data1", "read"},
+		{"bob", "", "write"},
+		{"carol", "data, with comma", " padded "},
+		{"dave", `say "hi"`, "read"},
+	}
+
+	for _, format := range []Format{FormatCSV, FormatJSON, FormatYAML} {
+		t.Run(string(format), func(t *testing.T) {
+			src := newSQLiteAdapter(t, openSQLite(t))
+			if err := src.AddPolicies("p", "p", rules); err != nil {
+				t.Fatalf("failed to add policies: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := src.Export(context.Background(), &buf, format); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			dst := newSQLiteAdapter(t, openSQLite(t))
+			if err := dst.Import(context.Background(), &buf, format, ImportReplace); err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+
+			var got bytes.Buffer
+			if err := dst.Export(context.Background(), &got, FormatJSON); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+			var want bytes.Buffer
+			if err := src.Export(context.Background(), &want, FormatJSON); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("got %s, want %s", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestBunAdapter_ExportExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	src := newSQLiteAdapter(t, openSQLite(t))
+	if err := src.AddPolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	for _, p := range []struct {
+		rule                 []string
+		validFrom, expiresAt time.Time
+	}{
+		{rule: []string{"bob", "data2", "write"}, expiresAt: now.Add(-time.Hour)},
+		{rule: []string{"carol", "data3", "read"}, expiresAt: now.Add(4 * time.Hour)},
+		{rule: []string{"dave", "data4", "read"}, validFrom: now.Add(time.Hour)},
+	} {
+		if err := src.AddPolicyWithExpiry(ctx, "p", "p", p.rule, p.validFrom, p.expiresAt); err != nil {
+			t.Fatalf("failed to add policy with expiry: %v", err)
+		}
+	}
+
+	// CSV has no expiry, so it holds the rules in effect
+	var buf bytes.Buffer
+	if err := src.Export(ctx, &buf, FormatCSV); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if got, want := buf.String(), "p, alice, data1, read\np, carol, data3, read\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// JSON and YAML keep the expiry of every rule that has not expired
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := src.Export(ctx, &buf, format); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+			dst := newSQLiteAdapter(t, openSQLite(t))
+			if err := dst.Import(ctx, &buf, format, ImportReplace); err != nil {
+				t.Fatalf("failed to import: %v", err)
+			}
+
+			got, err := dst.(*bunAdapter).collectPolicies(ctx, dst.(*bunAdapter).selectPolicies(dst.(*bunAdapter).db).Order("id"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []CasbinPolicy{
+				{ID: 1, PType: "p", V0: "alice", V1: "data1", V2: "read"},
+				{ID: 2, PType: "p", V0: "carol", V1: "data3", V2: "read", ExpiresAt: bun.NullTime{Time: now.Add(4 * time.Hour)}},
+				{ID: 3, PType: "p", V0: "dave", V1: "data4", V2: "read", ValidFrom: bun.NullTime{Time: now.Add(time.Hour)}},
+			}
+			if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+				t.Errorf("Import() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBunAdapter_ImportMode(t *testing.T) {
+	a := newSQLiteAdapter(t, openSQLite(t))
+	initPolicy(t, a)
+	input := "p, alice, data1, read\np, jack, data3, read\np, jack, data3, read\n"
+
+	// 1. merge keeps the stored rules and skips duplicates
+	if err := a.Import(context.Background(), strings.NewReader(input), FormatCSV, ImportMerge); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+	testGetPolicy(
+		t,
+		e,
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"jack", "data3", "read"}},
+	)
+
+	// 2. replace drops the stored rules
+	if err := a.Import(context.Background(), strings.NewReader(input), FormatCSV, ImportReplace); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"jack", "data3", "read"}})
+
+	// 3. a broken input leaves the stored rules untouched
+	if err := a.Import(context.Background(), strings.NewReader("p, x\np, a, b, c, d, e, f, g\n"), FormatCSV, ImportReplace); err == nil {
+		t.Fatal("expected an error for a rule with too many values")
+	}
+	if err := e.LoadPolicy(); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	testGetPolicy(t, e, [][]string{{"alice", "data1", "read"}, {"jack", "data3", "read"}})
+}
+
+func TestBunAdapter_ImportYAMLSequence(t *testing.T) {
+	a := newSQLiteAdapter(t, openSQLite(t))
+	input := "- ptype: p\n  rule: [alice, data1, read]\n- ptype: g\n  rule: [alice, data2_admin]\n"
+	if err := a.Import(context.Background(), strings.NewReader(input), FormatYAML, ImportReplace); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := a.Export(context.Background(), &buf, FormatCSV); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if want := "p, alice, data1, read\ng, alice, data2_admin\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,123 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// Rule is a policy rule with its ptype.
+type Rule struct {
+	PType  string
+	Values []string
+}
+
+func (r Rule) String() string {
+	return strings.Join(append([]string{r.PType}, r.Values...), ", ")
+}
+
+// key returns a comparable form of the rule.
+func (r Rule) key() string {
+	return strings.Join(append([]string{r.PType}, r.Values...), "\x00")
+}
+
+// DuplicateRule is a rule stored in more than one row.
+type DuplicateRule struct {
+	Rule
+	// Rows is the number of rows that hold the rule.
+	Rows int
+}
+
+// VerifyReport is the difference between the policy table and a model found by Verify.
+type VerifyReport struct {
+	// OnlyInDB are the rules stored in the policy table but missing from the model, in id order.
+	OnlyInDB []Rule
+	// OnlyInModel are the rules of the model that are not stored in the policy table.
+	OnlyInModel []Rule
+	// Duplicates are the rules stored in more than one row, in id order of their first row.
+	Duplicates []DuplicateRule
+}
+
+// Consistent reports whether the policy table and the model hold the same rules, each stored once.
+func (r VerifyReport) Consistent() bool {
+	return len(r.OnlyInDB) == 0 && len(r.OnlyInModel) == 0 && len(r.Duplicates) == 0
+}
+
+// Ping checks that the database is reachable and that the policy table exists,
+// returning ErrTableMissing when it does not.
+func (a *bunAdapter) Ping(ctx context.Context) (err error) {
+	ctx, op := a.startOperation(ctx, "Ping")
+	defer func() { op.end(err) }()
+
+	if err := a.db.PingContext(ctx); err != nil {
+		return classifyError(err)
+	}
+	table := a.modelTable()
+	live, err := a.liveColumns(ctx, a.db, table.Name)
+	if err != nil {
+		return classifyError(err)
+	}
+	if len(live) == 0 {
+		return fmt.Errorf("%w: %s", ErrTableMissing, table.Name)
+	}
+	return nil
+}
+
+// Verify compares the rules visible to the adapter with the rules of m, as LoadPolicy would load them,
+// to detect the drift left by a failed Auto-Save call.
+// The table is read in one pass, so Verify should be run against a model that is not being modified.
+// A model loaded by LoadFilteredPolicy holds only part of the table and is reported as such.
+func (a *bunAdapter) Verify(ctx context.Context, m model.Model) (_ VerifyReport, err error) {
+	ctx, op := a.startOperation(ctx, "Verify")
+	defer func() { op.end(err) }()
+
+	stored := make(map[string]int)
+	report := VerifyReport{OnlyInDB: make([]Rule, 0), OnlyInModel: make([]Rule, 0), Duplicates: make([]DuplicateRule, 0)}
+	order := make([]Rule, 0)
+	query := activeQuery(a, a.selectPolicies(a.readDB()), time.Now()).
+		Order("id")
+	if err := a.forEachPolicy(ctx, query, func(policy CasbinPolicy) error {
+		rule := Rule{PType: policy.PType, Values: policy.filterValues()}
+		if stored[rule.key()] == 0 {
+			order = append(order, rule)
+		}
+		stored[rule.key()]++
+		return nil
+	}); err != nil {
+		return VerifyReport{}, classifyError(err)
+	}
+	op.setRuleCount(len(order))
+
+	inModel := make(map[string]struct{})
+	for _, sec := range []string{"p", "g"} {
+		ptypes := make([]string, 0, len(m[sec]))
+		for ptype := range m[sec] {
+			ptypes = append(ptypes, ptype)
+		}
+		sort.Strings(ptypes)
+
+		for _, ptype := range ptypes {
+			for _, values := range m[sec][ptype].Policy {
+				rule := Rule{PType: ptype, Values: values}
+				inModel[rule.key()] = struct{}{}
+				if stored[rule.key()] == 0 {
+					report.OnlyInModel = append(report.OnlyInModel, rule)
+				}
+			}
+		}
+	}
+
+	for _, rule := range order {
+		if _, ok := inModel[rule.key()]; !ok {
+			report.OnlyInDB = append(report.OnlyInDB, rule)
+		}
+		if rows := stored[rule.key()]; rows > 1 {
+			report.Duplicates = append(report.Duplicates, DuplicateRule{Rule: rule, Rows: rows})
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,80 @@
+package casbinbunadapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBunAdapter_Ping(t *testing.T) {
+	ctx := context.Background()
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	if err := a.Ping(ctx); err != nil {
+		t.Errorf("failed to ping: %v", err)
+	}
+
+	if _, err := a.(*bunAdapter).db.ExecContext(ctx, "DROP TABLE casbin_policies"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Ping(ctx); !errors.Is(err, ErrTableMissing) {
+		t.Errorf("got %v, want ErrTableMissing", err)
+	}
+
+	if err := a.(*bunAdapter).db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Ping(ctx); err == nil {
+		t.Error("got no error from a closed database")
+	}
+}
+
+func TestBunAdapter_Verify(t *testing.T) {
+	ctx := context.Background()
+
+	a := newSQLiteAdapter(t, openSQLite(t))
+	initPolicy(t, a)
+	e, err := casbin.NewEnforcer("testdata/rbac_model.conf", a)
+	if err != nil {
+		t.Fatalf("failed to create enforcer: %v", err)
+	}
+
+	report, err := a.Verify(ctx, e.GetModel())
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if !report.Consistent() {
+		t.Errorf("got %+v, want a consistent report", report)
+	}
+
+	// drift from Auto-Save calls that failed or bypassed the enforcer
+	e.EnableAutoSave(false)
+	if _, err := e.AddPolicy("carol", "data3", "read"); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+	if err := a.AddPolicies("p", "p", [][]string{{"dave", "data4", "read"}, {"alice", "data1", "read"}}); err != nil {
+		t.Fatalf("failed to add policies: %v", err)
+	}
+	if err := a.AddPolicy("g", "g", []string{"bob", "data2_admin"}); err != nil {
+		t.Fatalf("failed to add policy: %v", err)
+	}
+
+	report, err = a.Verify(ctx, e.GetModel())
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	want := VerifyReport{
+		OnlyInDB:    []Rule{{PType: "p", Values: []string{"dave", "data4", "read"}}, {PType: "g", Values: []string{"bob", "data2_admin"}}},
+		OnlyInModel: []Rule{{PType: "p", Values: []string{"carol", "data3", "read"}}},
+		Duplicates:  []DuplicateRule{{Rule: Rule{PType: "p", Values: []string{"alice", "data1", "read"}}, Rows: 2}},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("Verify() mismatch (-want +got):\n%s", diff)
+	}
+	if report.Consistent() {
+		t.Error("got a consistent report, want drift")
+	}
+}